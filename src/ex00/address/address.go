@@ -0,0 +1,101 @@
+package address
+
+import (
+	"strings"
+)
+
+// Address разобранный адрес вида "gorod Moskva, ulitsa X, dom N, korpus M".
+// Номера дома и строения хранятся без типа, тип - отдельно, как у улицы:
+// "dom 9" -> House "9", HouseType "dom"; "korpus 1, stroenie 2" -> Building "1, 2", BuildingType "korpus, stroenie"
+type Address struct {
+	City         string `json:"city,omitempty"`
+	District     string `json:"district,omitempty"`
+	StreetType   string `json:"street_type,omitempty"`
+	Street       string `json:"street,omitempty"`
+	HouseType    string `json:"house_type,omitempty"`
+	House        string `json:"house,omitempty"`
+	BuildingType string `json:"building_type,omitempty"`
+	Building     string `json:"building,omitempty"`
+}
+
+var streetTypes = []string{
+	"ulitsa", "prospekt", "pereulok", "shosse", "bul'var", "proezd", "naberezhnaja", "ploschad'",
+	"alleja", "tupik", "linija", "prosek", "kilometr", "mikrorajon", "kvartal", "territorija",
+}
+
+// поселения внутри города (Зеленоград, поселения Новой Москвы)
+var districtTypes = []string{"gorod", "poselenie", "poselok", "rabochij poselok", "dachnyj poselok", "derevnja", "selo"}
+
+var houseTypes = []string{"dom", "vladenie", "domovladenie"}
+
+var buildingTypes = []string{"korpus", "stroenie", "sooruzhenie"}
+
+// Parse разбирает адрес по частям через запятую; то, что не распознали, просто пропускаем
+func Parse(s string) Address {
+	var a Address
+	var buildings, buildingKinds []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if kind, value, ok := cutPrefix(part, houseTypes); ok {
+			if a.House == "" {
+				a.HouseType, a.House = kind, value
+			}
+			continue
+		}
+		if kind, value, ok := cutPrefix(part, buildingTypes); ok {
+			buildingKinds = append(buildingKinds, kind)
+			buildings = append(buildings, value)
+			continue
+		}
+		if _, value, ok := cutPrefix(part, districtTypes); ok {
+			if a.City == "" && strings.HasPrefix(part, "gorod ") {
+				a.City = value
+			} else if a.District == "" {
+				a.District = value
+			}
+			continue
+		}
+		if a.Street == "" {
+			if kind, name, ok := cutStreetType(part); ok {
+				a.StreetType, a.Street = kind, name
+			}
+		}
+	}
+	a.BuildingType = strings.Join(buildingKinds, ", ")
+	a.Building = strings.Join(buildings, ", ")
+	return a
+}
+
+// cutPrefix отрезает тип ("dom 9" -> "dom", "9"); из подходящих типов берем самый длинный
+func cutPrefix(part string, kinds []string) (string, string, bool) {
+	best := ""
+	for _, kind := range kinds {
+		if strings.HasPrefix(part, kind+" ") && len(kind) > len(best) {
+			best = kind
+		}
+	}
+	if best == "" {
+		return "", "", false
+	}
+	return best, strings.TrimSpace(part[len(best):]), true
+}
+
+// cutStreetType тип улицы может стоять и до названия, и после: "ulitsa Petrovka", "Tverskaja ulitsa"
+func cutStreetType(part string) (string, string, bool) {
+	words := strings.Fields(part)
+	if len(words) < 2 {
+		return "", "", false
+	}
+	for i, w := range words {
+		for _, kind := range streetTypes {
+			if w == kind {
+				name := append(append([]string{}, words[:i]...), words[i+1:]...)
+				return kind, strings.Join(name, " "), true
+			}
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,60 @@
+package address
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Address
+	}{
+		{
+			"full",
+			"gorod Moskva, ulitsa Petrovka, dom 9, korpus 1",
+			Address{City: "Moskva", StreetType: "ulitsa", Street: "Petrovka", HouseType: "dom", House: "9", BuildingType: "korpus", Building: "1"},
+		},
+		{
+			"street type after the name",
+			"gorod Moskva, Tverskaja ulitsa, dom 12",
+			Address{City: "Moskva", StreetType: "ulitsa", Street: "Tverskaja", HouseType: "dom", House: "12"},
+		},
+		{
+			"several buildings",
+			"gorod Moskva, Leninskij prospekt, dom 30, korpus 2, stroenie 5",
+			Address{City: "Moskva", StreetType: "prospekt", Street: "Leninskij", HouseType: "dom", House: "30", BuildingType: "korpus, stroenie", Building: "2, 5"},
+		},
+		{
+			"longest house type wins",
+			"gorod Moskva, ulitsa Lesnaja, domovladenie 3",
+			Address{City: "Moskva", StreetType: "ulitsa", Street: "Lesnaja", HouseType: "domovladenie", House: "3"},
+		},
+		{
+			"Zelenograd is a district",
+			"gorod Moskva, gorod Zelenograd, korpus 1106",
+			Address{City: "Moskva", District: "Zelenograd", BuildingType: "korpus", Building: "1106"},
+		},
+		{
+			"settlement",
+			"gorod Moskva, poselenie Sosenskoe, derevnja Sosenki, ulitsa Sadovaja, vladenie 7",
+			Address{City: "Moskva", District: "Sosenskoe", StreetType: "ulitsa", Street: "Sadovaja", HouseType: "vladenie", House: "7"},
+		},
+		{
+			"only the first house",
+			"ulitsa Arbat, dom 1, dom 2",
+			Address{StreetType: "ulitsa", Street: "Arbat", HouseType: "dom", House: "1"},
+		},
+		{
+			"unknown parts are skipped",
+			" , Petrovka, 9",
+			Address{},
+		},
+		{"empty", "", Address{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.in); got != tt.want {
+				t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package dataset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// DefaultName набор по умолчанию - исходный индекс places
+const DefaultName = "places"
+
+// Dataset Index - alias, под которым загрузчик создает индекс; Validation - свой validation.json
+// (у другого города другие границы), пустой - общий. Public - читается без учетных данных,
+// набор по умолчанию публичный всегда
+type Dataset struct {
+	Index      string `json:"index"`
+	Title      string `json:"title,omitempty"`
+	Validation string `json:"validation,omitempty"`
+	Public     bool   `json:"public,omitempty"`
+}
+
+type Config struct {
+	Default  string             `json:"default"`
+	Datasets map[string]Dataset `json:"datasets"`
+}
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func DefaultConfig() Config {
+	return Config{
+		Default:  DefaultName,
+		Datasets: map[string]Dataset{DefaultName: {Index: DefaultName, Public: true}},
+	}
+}
+
+func LoadConfig(path string) (Config, error) {
+	const op = "dataset.LoadConfig"
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return Config{}, errors.New(op + ": " + err.Error())
+	}
+	if err := cfg.normalize(); err != nil {
+		return Config{}, errors.New(op + ": " + err.Error())
+	}
+	return cfg, nil
+}
+
+// normalize index по умолчанию совпадает с именем набора
+func (c *Config) normalize() error {
+	if len(c.Datasets) == 0 {
+		return errors.New("no datasets")
+	}
+	for name, d := range c.Datasets {
+		if !nameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid dataset name %q", name)
+		}
+		if d.Index == "" {
+			d.Index = name
+			c.Datasets[name] = d
+		}
+	}
+	if c.Default == "" && len(c.Datasets) == 1 {
+		c.Default = c.Names()[0]
+	}
+	d, ok := c.Datasets[c.Default]
+	if !ok {
+		return fmt.Errorf("default dataset %q is not configured", c.Default)
+	}
+	d.Public = true
+	c.Datasets[c.Default] = d
+	return nil
+}
+
+// Get пустое имя - набор по умолчанию
+func (c Config) Get(name string) (Dataset, bool) {
+	if name == "" {
+		name = c.Default
+	}
+	d, ok := c.Datasets[name]
+	return d, ok
+}
+
+func (c Config) Names() []string {
+	names := make([]string, 0, len(c.Datasets))
+	for name := range c.Datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,194 @@
+package dedup
+
+import (
+	"Day03/ex00/loader"
+	"Day03/ex00/translit"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+)
+
+type Mode string
+
+const (
+	ModeOff    Mode = "off"
+	ModeReport Mode = "report" // только отчет, данные не меняем
+	ModeSkip   Mode = "skip"   // оставляем первую запись группы
+	ModeMerge  Mode = "merge"  // первая запись группы забирает телефоны остальных
+	ModeTag    Mode = "tag"    // грузим все, но с общим group_id
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeOff, ModeReport, ModeSkip, ModeMerge, ModeTag:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown dedup mode %q, expected off, report, skip, merge or tag", s)
+}
+
+// DefaultRadius в метрах: одно и то же место с разными адресами (угол двух улиц, корпуса) обычно в пределах этого
+const DefaultRadius = 100
+
+// Group Id совпадает с id первой записи группы
+type Group struct {
+	Id      string
+	Members []int // индексы в исходном срезе, по возрастанию
+}
+
+// Find дубликаты: одинаковое нормализованное название и тот же адрес или расстояние не больше radius.
+// Сетевые кафе с одним названием на разных улицах дубликатами не считаются, разные заведения по одному
+// адресу тоже (у фудкортов бывает общий телефон)
+func Find(data []loader.Data, radius float64) []Group {
+	parent := make([]int, len(data))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		a, b := find(i), find(j)
+		if a > b {
+			a, b = b, a
+		}
+		parent[b] = a
+	}
+
+	byName := map[string][]int{}
+	addresses := make([]string, len(data))
+	for i, d := range data {
+		addresses[i] = normalize(d.Address)
+		if name := normalize(d.Name); name != "" {
+			byName[name] = append(byName[name], i)
+		}
+	}
+	for _, idx := range byName {
+		for x, i := range idx {
+			for _, j := range idx[x+1:] {
+				if addresses[i] == addresses[j] || distance(data[i].Location, data[j].Location) <= radius {
+					union(i, j)
+				}
+			}
+		}
+	}
+	members := map[int][]int{}
+	var roots []int
+	for i := range data {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	var groups []Group
+	for _, root := range roots {
+		if len(members[root]) > 1 {
+			groups = append(groups, Group{Id: data[root].Id, Members: members[root]})
+		}
+	}
+	return groups
+}
+
+// Apply возвращает новый срез, исходный не трогаем
+func Apply(data []loader.Data, groups []Group, mode Mode) []loader.Data {
+	if mode == ModeOff || mode == ModeReport || len(groups) == 0 {
+		return data
+	}
+	result := make([]loader.Data, len(data))
+	copy(result, data)
+	drop := make([]bool, len(data))
+	for _, g := range groups {
+		first := g.Members[0]
+		for _, i := range g.Members {
+			switch mode {
+			case ModeTag:
+				result[i].GroupId = g.Id
+			case ModeSkip, ModeMerge:
+				if i == first {
+					continue
+				}
+				drop[i] = true
+				if mode == ModeMerge {
+					result[first] = merge(result[first], data[i])
+				}
+			}
+		}
+	}
+	kept := result[:0]
+	for i, d := range result {
+		if !drop[i] {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// merge из дубликата берем только телефоны, которых еще нет
+func merge(dst loader.Data, src loader.Data) loader.Data {
+	if dst.Phone == "" {
+		dst.Phone = src.Phone
+		dst.PhoneE164 = src.PhoneE164
+		return dst
+	}
+	phones := append([]string{}, dst.PhoneE164...)
+	added := false
+	for _, p := range src.PhoneE164 {
+		if !contains(phones, p) {
+			phones = append(phones, p)
+			added = true
+		}
+	}
+	if added {
+		dst.Phone += ";" + src.Phone
+	}
+	dst.PhoneE164 = phones
+	return dst
+}
+
+func WriteReport(w io.Writer, data []loader.Data, groups []Group) {
+	total := 0
+	for _, g := range groups {
+		total += len(g.Members) - 1
+		_, _ = fmt.Fprintf(w, "group %s (%d places)\n", g.Id, len(g.Members))
+		for _, i := range g.Members {
+			_, _ = fmt.Fprintf(w, "\t%s\t%s\t%s\t%s\n", data[i].Id, data[i].Name, data[i].Address, data[i].Phone)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%d duplicate groups, %d extra records\n", len(groups), total)
+}
+
+// normalize регистр, кавычки, пунктуация и кириллица не должны влиять на сравнение.
+// Апострофы (мягкий знак в транслите) выбрасываем, как char_filter cyr_to_lat в индексе
+func normalize(s string) string {
+	s = strings.ToLower(apostrophes.Replace(translit.ToLatin(s)))
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+var apostrophes = strings.NewReplacer("'", "", "’", "", "`", "")
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// distance в метрах, формула гаверсинусов
+func distance(a, b loader.Location) float64 {
+	const earthRadius = 6371000
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
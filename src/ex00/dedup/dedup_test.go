@@ -0,0 +1,137 @@
+package dedup
+
+import (
+	"Day03/ex00/loader"
+	"reflect"
+	"testing"
+)
+
+func place(id, name, address string, lat, lon float64, phones ...string) loader.Data {
+	d := loader.Data{Id: id, Name: name, Address: address, Location: loader.Location{Latitude: lat, Longitude: lon}}
+	if len(phones) > 0 {
+		d.Phone = phones[0]
+		d.PhoneE164 = phones
+	}
+	return d
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name string
+		data []loader.Data
+		want []Group
+	}{
+		{
+			"same name and address far apart",
+			[]loader.Data{
+				place("1", "Kafe Rus'", "ulitsa Petrovka, dom 1", 55.76, 37.61),
+				place("2", "kafe  rus'", "Ulitsa Petrovka dom 1", 55.70, 37.50),
+			},
+			[]Group{{Id: "1", Members: []int{0, 1}}},
+		},
+		{
+			"same name within radius",
+			[]loader.Data{
+				place("1", "Shokoladnitsa", "ulitsa Petrovka, dom 1", 55.7600, 37.6100),
+				place("2", "Шоколадница", "Petrovskij pereulok, dom 2", 55.7603, 37.6102),
+			},
+			[]Group{{Id: "1", Members: []int{0, 1}}},
+		},
+		{
+			"soft sign spelled or lost",
+			[]loader.Data{
+				place("1", "Kafe Bul'var", "Tverskoj bul'var, dom 1", 55.76, 37.61),
+				place("2", "Кафе Бульвар", "Tverskoj bulvar dom 1", 55.70, 37.50),
+				place("3", "Kafe Bulvar", "Тверской бульвар, дом 1", 55.70, 37.50),
+			},
+			[]Group{{Id: "1", Members: []int{0, 1, 2}}},
+		},
+		{
+			"chain on different streets",
+			[]loader.Data{
+				place("1", "Shokoladnitsa", "ulitsa Petrovka, dom 1", 55.76, 37.61),
+				place("2", "Shokoladnitsa", "ulitsa Arbat, dom 2", 55.75, 37.59),
+			},
+			nil,
+		},
+		{
+			"different places at one address",
+			[]loader.Data{
+				place("1", "Pelmennaja", "ulitsa Petrovka, dom 1", 55.76, 37.61),
+				place("2", "Burgernaja", "ulitsa Petrovka, dom 1", 55.76, 37.61),
+			},
+			nil,
+		},
+		{
+			"transitive group",
+			[]loader.Data{
+				place("1", "Kofe", "a", 55.7600, 37.61),
+				place("2", "Drugoe", "b", 55.7600, 37.61),
+				place("3", "Kofe", "c", 55.7608, 37.61),
+				place("4", "Kofe", "d", 55.7616, 37.61),
+			},
+			[]Group{{Id: "1", Members: []int{0, 2, 3}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Find(tt.data, DefaultRadius); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	data := []loader.Data{
+		place("1", "Kofe", "a", 55.76, 37.61, "+74951111111"),
+		place("2", "Chaj", "b", 55.70, 37.50),
+		place("3", "Kofe", "a", 55.76, 37.61, "+74952222222"),
+		place("4", "Kofe", "a", 55.76, 37.61, "+74951111111"),
+	}
+	groups := []Group{{Id: "1", Members: []int{0, 2, 3}}}
+	tests := []struct {
+		mode     Mode
+		wantIds  []string
+		wantTags []string
+		first    []string
+	}{
+		{ModeOff, []string{"1", "2", "3", "4"}, []string{"", "", "", ""}, []string{"+74951111111"}},
+		{ModeReport, []string{"1", "2", "3", "4"}, []string{"", "", "", ""}, []string{"+74951111111"}},
+		{ModeTag, []string{"1", "2", "3", "4"}, []string{"1", "", "1", "1"}, []string{"+74951111111"}},
+		{ModeSkip, []string{"1", "2"}, []string{"", ""}, []string{"+74951111111"}},
+		{ModeMerge, []string{"1", "2"}, []string{"", ""}, []string{"+74951111111", "+74952222222"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			got := Apply(data, groups, tt.mode)
+			var ids, tags []string
+			for _, d := range got {
+				ids, tags = append(ids, d.Id), append(tags, d.GroupId)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) || !reflect.DeepEqual(tags, tt.wantTags) {
+				t.Fatalf("ids %v tags %v, want %v %v", ids, tags, tt.wantIds, tt.wantTags)
+			}
+			if !reflect.DeepEqual(got[0].PhoneE164, tt.first) {
+				t.Errorf("phones %v, want %v", got[0].PhoneE164, tt.first)
+			}
+		})
+	}
+	// исходный срез не меняется
+	if data[0].GroupId != "" || len(data[0].PhoneE164) != 1 || len(data) != 4 {
+		t.Errorf("Apply modified its input: %+v", data[0])
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, s := range []string{"off", "report", "skip", "merge", "tag"} {
+		if m, err := ParseMode(s); err != nil || string(m) != s {
+			t.Errorf("ParseMode(%q) = %q, %v", s, m, err)
+		}
+	}
+	for _, s := range []string{"", "Tag", "drop"} {
+		if _, err := ParseMode(s); err == nil {
+			t.Errorf("ParseMode(%q) expected error", s)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"Day03/ex00/loader"
+	"Day03/ex00/mapping"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// dryRunSample сколько документов показать, если -render не задан
+const dryRunSample = 3
+
+// dryRun проверяет данные и маппинг без обращения к эластику: разбор, валидация и dedup уже
+// отработали и напечатали свои отчеты, здесь сверка документов с маппингом и итог
+func dryRun(data []loader.Data, rowErrors []loader.RowError, version mapping.Version, renderFile string) error {
+	const op = "dryRun"
+	index := version.IndexName(indexName)
+	if _, err := version.IndexBody(); err != nil {
+		return errors.New(op + ": " + version.File + ": " + err.Error())
+	}
+	fmt.Printf("mapping: %s is valid JSON\n", version.File)
+
+	problems := map[string]int{}
+	dynamic := map[string]int{}
+	examples := map[string]string{}
+	render, closeRender, err := openRender(renderFile)
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	for i, d := range data {
+		body, err := json.Marshal(d)
+		if err != nil {
+			return errors.New(op + ": " + err.Error())
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return errors.New(op + ": " + err.Error())
+		}
+		docProblems, docDynamic := version.CheckDocument(doc)
+		for _, p := range docProblems {
+			if problems[p]++; problems[p] == 1 {
+				examples[p] = d.Id
+			}
+		}
+		for _, field := range docDynamic {
+			dynamic[field]++
+		}
+		if render != nil {
+			meta, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": index, "_id": d.Id}})
+			_, _ = fmt.Fprintf(render, "%s\n%s\n", meta, body)
+		} else if i < dryRunSample {
+			pretty, _ := json.MarshalIndent(d, "", "  ")
+			fmt.Printf("document %s:\n%s\n", d.Id, pretty)
+		}
+	}
+	if err := closeRender(); err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+
+	for _, e := range rowErrors {
+		fmt.Printf("row %d: %s\n", e.Row, e.Error)
+	}
+	for _, p := range sortedKeys(problems) {
+		fmt.Printf("mapping: %s (%d documents, e.g. id %s)\n", p, problems[p], examples[p])
+	}
+	for _, field := range sortedKeys(dynamic) {
+		fmt.Printf("mapping warning: %s is not in the mapping, elasticsearch will map it dynamically (%d documents)\n", field, dynamic[field])
+	}
+	fmt.Printf("dry run: %d rows not parsed, %d documents would be indexed into %s (alias %s), %d mapping problems\n",
+		len(rowErrors), len(data), index, indexName, len(problems))
+	if render != nil {
+		fmt.Println("bulk body written to", renderFile)
+	}
+	if len(rowErrors) > 0 || len(problems) > 0 {
+		return errors.New(op + ": data is not ready to be loaded")
+	}
+	return nil
+}
+
+// openRender тело bulk-запроса в файл; без файла - nil и печатаем только пример
+func openRender(path string) (*bufio.Writer, func() error, error) {
+	if path == "" {
+		return nil, func() error { return nil }, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := bufio.NewWriter(file)
+	return w, func() error {
+		if err := w.Flush(); err != nil {
+			_ = file.Close()
+			return err
+		}
+		return file.Close()
+	}, nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,233 @@
+package input
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Stdin путь, означающий стандартный ввод
+const Stdin = "-"
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// sniffSize сколько байт смотрим для определения сжатия и кодировки
+const sniffSize = 4096
+
+// Options Member - имя или glob (*.csv) файла внутри zip; Encoding - auto или имя кодировки (windows-1251, utf-8, koi8-r)
+type Options struct {
+	Member   string
+	Encoding string
+}
+
+// Source уже распакованный и перекодированный в UTF-8 поток
+type Source struct {
+	io.Reader
+	Compression string
+	Member      string
+	Encoding    string
+	closers     []io.Closer
+}
+
+func (s *Source) Close() error {
+	var err error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if cerr := s.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *Source) String() string {
+	desc := s.Encoding
+	if s.Compression != "" {
+		desc = s.Compression + ", " + desc
+	}
+	if s.Member != "" {
+		desc += ", member " + s.Member
+	}
+	return desc
+}
+
+// Open файл или stdin; сжатие определяется по первым байтам, а не по расширению
+func Open(name string, opts Options) (*Source, error) {
+	const op = "input.Open"
+	src := &Source{}
+	var r io.Reader
+	if name == Stdin {
+		r = os.Stdin
+	} else {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, errors.New(op + ": " + err.Error())
+		}
+		src.closers = append(src.closers, file)
+		r = file
+	}
+	if err := src.decompress(r, opts.Member); err != nil {
+		_ = src.Close()
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	if err := src.decode(opts.Encoding); err != nil {
+		_ = src.Close()
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	return src, nil
+}
+
+func (s *Source) decompress(r io.Reader, member string) error {
+	br := bufio.NewReaderSize(r, sniffSize)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return errors.New("gzip: " + err.Error())
+		}
+		s.Compression, s.Reader = "gzip", gz
+		s.closers = append(s.closers, gz)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return errors.New("zstd: " + err.Error())
+		}
+		s.Compression, s.Reader = "zstd", zr
+		s.closers = append(s.closers, closerFunc(func() error { zr.Close(); return nil }))
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		// zip читается с конца, поэтому архив целиком в памяти (для stdin иначе никак)
+		body, err := io.ReadAll(br)
+		if err != nil {
+			return errors.New("zip: " + err.Error())
+		}
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			return errors.New("zip: " + err.Error())
+		}
+		f, err := chooseMember(zr.File, member)
+		if err != nil {
+			return errors.New("zip: " + err.Error())
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return errors.New("zip: " + err.Error())
+		}
+		s.Compression, s.Member, s.Reader = "zip", f.Name, rc
+		s.closers = append(s.closers, rc)
+	default:
+		s.Reader = br
+	}
+	return nil
+}
+
+// chooseMember без имени берем единственный файл, а если их несколько - единственный с данными по расширению
+func chooseMember(files []*zip.File, member string) (*zip.File, error) {
+	var names []string
+	var candidates []*zip.File
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		names = append(names, f.Name)
+		if member != "" {
+			if f.Name == member {
+				return f, nil
+			}
+			if ok, _ := path.Match(member, path.Base(f.Name)); ok {
+				candidates = append(candidates, f)
+			}
+			continue
+		}
+		switch strings.ToLower(path.Ext(f.Name)) {
+		case ".csv", ".tsv", ".txt", ".ndjson", ".jsonl":
+			candidates = append(candidates, f)
+		}
+	}
+	if member == "" && len(names) == 1 {
+		for _, f := range files {
+			if !f.FileInfo().IsDir() {
+				return f, nil
+			}
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	if len(candidates) == 0 && member != "" {
+		return nil, fmt.Errorf("no member matches %q, archive has: %s", member, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("cannot choose a member, use -member; archive has: %s", strings.Join(names, ", "))
+}
+
+// decode auto: BOM, затем валидный UTF-8, иначе считаем, что это Windows-1251 (выгрузки из Excel)
+func (s *Source) decode(name string) error {
+	br := bufio.NewReaderSize(s.Reader, sniffSize)
+	s.Reader = br
+	if name != "" && !strings.EqualFold(name, "auto") {
+		enc, err := htmlindex.Get(name)
+		if err != nil {
+			return fmt.Errorf("unknown encoding %q", name)
+		}
+		canonical, _ := htmlindex.Name(enc)
+		s.setEncoding(canonical, enc)
+		// BOM бывает и при явно заданной кодировке; после декодера это уже UTF-8 BOM
+		out := bufio.NewReader(s.Reader)
+		if head, _ := out.Peek(3); bytes.Equal(head, utf8BOM) {
+			_, _ = out.Discard(3)
+		}
+		s.Reader = out
+		return nil
+	}
+	head, _ := br.Peek(sniffSize)
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		_, _ = br.Discard(3)
+		s.Encoding = "utf-8 (BOM)"
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}), bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		s.setEncoding("utf-16", unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM))
+	case utf8.Valid(trimIncompleteRune(head)):
+		s.Encoding = "utf-8"
+	default:
+		s.setEncoding("windows-1251", charmap.Windows1251)
+	}
+	return nil
+}
+
+func (s *Source) setEncoding(name string, enc encoding.Encoding) {
+	s.Encoding = name
+	if name == "utf-8" {
+		return
+	}
+	s.Reader = enc.NewDecoder().Reader(s.Reader)
+}
+
+// trimIncompleteRune Peek мог разрезать последний символ пополам
+func trimIncompleteRune(b []byte) []byte {
+	i := len(b) - 1
+	for i > 0 && len(b)-i < utf8.UTFMax && !utf8.RuneStart(b[i]) {
+		i--
+	}
+	if i >= 0 && !utf8.FullRune(b[i:]) {
+		return b[:i]
+	}
+	return b
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
@@ -0,0 +1,134 @@
+package input
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sample = "id\tname\n1\tКафе\n"
+
+func gzipped(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, _ = w.Write([]byte(s))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func zstded(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	w, err := zstd.NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte(s))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// zipped files - пары имя, содержимое
+func zipped(t *testing.T, files ...string) []byte {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for i := 0; i < len(files); i += 2 {
+		f, err := w.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = f.Write([]byte(files[i+1]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func encoded(t *testing.T, s string, enc *encoding.Encoder) []byte {
+	b, err := enc.Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestOpen(t *testing.T) {
+	long := "a" + strings.Repeat("я", sniffSize)
+	tests := []struct {
+		name            string
+		body            []byte
+		opts            Options
+		want            string
+		wantCompression string
+		wantMember      string
+		wantEncoding    string
+		wantErr         bool
+	}{
+		{"plain utf-8", []byte(sample), Options{}, sample, "", "", "utf-8", false},
+		{"utf-8 cut at sniff size", []byte(long), Options{}, long, "", "", "utf-8", false},
+		{"utf-8 with BOM", append([]byte{0xef, 0xbb, 0xbf}, sample...), Options{}, sample, "", "", "utf-8 (BOM)", false},
+		{"utf-16", encoded(t, sample, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()), Options{}, sample, "", "", "utf-16", false},
+		{"windows-1251 fallback", encoded(t, sample, charmap.Windows1251.NewEncoder()), Options{}, sample, "", "", "windows-1251", false},
+		{"explicit koi8-r", encoded(t, sample, charmap.KOI8R.NewEncoder()), Options{Encoding: "koi8-r"}, sample, "", "", "koi8-r", false},
+		{"explicit utf-8 with BOM", append([]byte{0xef, 0xbb, 0xbf}, sample...), Options{Encoding: "utf-8"}, sample, "", "", "utf-8", false},
+		{"explicit utf-16le with BOM", encoded(t, sample, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()), Options{Encoding: "utf-16le"}, sample, "", "", "utf-16le", false},
+		{"unknown encoding", []byte(sample), Options{Encoding: "klingon"}, "", "", "", "", true},
+		{"gzip", gzipped(t, sample), Options{}, sample, "gzip", "", "utf-8", false},
+		{"gzip with windows-1251", gzipped(t, string(encoded(t, sample, charmap.Windows1251.NewEncoder()))), Options{}, sample, "gzip", "", "windows-1251", false},
+		{"zstd", zstded(t, sample), Options{}, sample, "zstd", "", "utf-8", false},
+		{"zip single file", zipped(t, "data.bin", sample), Options{}, sample, "zip", "data.bin", "utf-8", false},
+		{"zip picks data file", zipped(t, "readme.md", "x", "data.csv", sample), Options{}, sample, "zip", "data.csv", "utf-8", false},
+		{"zip member glob", zipped(t, "a.csv", "x", "dir/b.tsv", sample), Options{Member: "*.tsv"}, sample, "zip", "dir/b.tsv", "utf-8", false},
+		{"zip ambiguous", zipped(t, "a.csv", "x", "b.csv", sample), Options{}, "", "", "", "", true},
+		{"zip member not found", zipped(t, "a.csv", sample), Options{Member: "b.csv"}, "", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "input")
+			if err := os.WriteFile(name, tt.body, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			src, err := Open(name, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					_ = src.Close()
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = src.Close() }()
+			got, err := io.ReadAll(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("content %q, want %q", got, tt.want)
+			}
+			if src.Compression != tt.wantCompression || src.Member != tt.wantMember || src.Encoding != tt.wantEncoding {
+				t.Errorf("source %q/%q/%q, want %q/%q/%q", src.Compression, src.Member, src.Encoding, tt.wantCompression, tt.wantMember, tt.wantEncoding)
+			}
+		})
+	}
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing"), Options{}); err == nil {
+		t.Error("expected error")
+	}
+}
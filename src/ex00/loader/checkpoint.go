@@ -0,0 +1,178 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkpointEvery как часто сбрасываем прогресс на диск, в строках
+const checkpointEvery = 500
+
+type checkpointState struct {
+	Source    string    `json:"source"`
+	Index     string    `json:"index"`
+	Rows      int       `json:"rows"`
+	LastRow   int       `json:"last_row"`
+	Failed    []int     `json:"failed,omitempty"` // строки, которые эластик отверг окончательно
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Checkpoint хранит номер строки, до которой включительно все документы проиндексированы.
+// BulkIndexer шлет пачки параллельно, поэтому двигаем отметку только по сплошному префиксу.
+// Строки узнаем по id, документы без id не отслеживаются.
+// Окончательно отвергнутая строка тоже считается обработанной, иначе отметка на ней встанет
+type Checkpoint struct {
+	path   string
+	mu     sync.Mutex
+	state  checkpointState
+	pos    map[string]int
+	done   []bool
+	failed map[int]bool
+	saved  int
+}
+
+// OpenCheckpoint прогресс из файла берется, только если он от того же источника с тем же числом строк
+func OpenCheckpoint(path string, source string, data []Data) (*Checkpoint, error) {
+	const op = "loader.OpenCheckpoint"
+	c := &Checkpoint{
+		path:   path,
+		state:  checkpointState{Source: source, Rows: len(data)},
+		pos:    make(map[string]int, len(data)),
+		done:   make([]bool, len(data)),
+		failed: make(map[int]bool),
+	}
+	for i, d := range data {
+		if d.Id != "" {
+			c.pos[d.Id] = i
+		}
+	}
+	file, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	var saved checkpointState
+	if err := json.Unmarshal(file, &saved); err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	if saved.Source == source && saved.Rows == len(data) && saved.LastRow <= len(data) {
+		c.state = saved
+		for i := 0; i < saved.LastRow; i++ {
+			c.done[i] = true
+		}
+		// строки после отметки загрузятся заново, их прежние отказы не в счет
+		for _, i := range saved.Failed {
+			if i < saved.LastRow {
+				c.failed[i] = true
+			}
+		}
+		c.saved = saved.LastRow
+	}
+	return c, nil
+}
+
+// LastRow 0 - начинать сначала
+func (c *Checkpoint) LastRow() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.LastRow
+}
+
+func (c *Checkpoint) Index() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Index
+}
+
+// Start новая загрузка в index, прежний прогресс сбрасывается
+func (c *Checkpoint) Start(index string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state.Index, c.state.LastRow, c.saved = index, 0, 0
+	for i := range c.done {
+		c.done[i] = false
+	}
+	c.failed = make(map[int]bool)
+	return c.save()
+}
+
+// Failed сколько строк эластик отверг окончательно, включая прерванные запуски
+func (c *Checkpoint) Failed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.failed)
+}
+
+func (c *Checkpoint) Done(d Data) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mark(d, false)
+}
+
+// Fail строка отвергнута без права на повтор: загрузку она не держит, но запоминается
+func (c *Checkpoint) Fail(d Data) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mark(d, true)
+}
+
+func (c *Checkpoint) mark(d Data, failed bool) {
+	i, ok := c.pos[d.Id]
+	if !ok {
+		return
+	}
+	c.done[i] = true
+	if failed {
+		c.failed[i] = true
+	}
+	for c.state.LastRow < len(c.done) && c.done[c.state.LastRow] {
+		c.state.LastRow++
+	}
+	if c.state.LastRow-c.saved >= checkpointEvery {
+		_ = c.save() // не удалось записать - при повторном запуске просто начнем раньше
+	}
+}
+
+func (c *Checkpoint) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+// save пишем во временный файл и переименовываем, чтобы падение не оставило половину json
+func (c *Checkpoint) save() error {
+	const op = "Checkpoint.save"
+	c.state.UpdatedAt = time.Now()
+	c.state.Failed = c.state.Failed[:0]
+	for i := range c.failed {
+		c.state.Failed = append(c.state.Failed, i)
+	}
+	sort.Ints(c.state.Failed)
+	body, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	c.saved = c.state.LastRow
+	return nil
+}
+
+// Remove после успешной загрузки и сверки
+func (c *Checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.New("Checkpoint.Remove: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package loader
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func rows(n int) []Data {
+	data := make([]Data, n)
+	for i := range data {
+		data[i].Id = strconv.Itoa(i + 1)
+	}
+	return data
+}
+
+func TestCheckpointPrefix(t *testing.T) {
+	type step struct {
+		row  int // индекс строки; отрицательный - Fail строки -row-1
+		want int
+	}
+	tests := []struct {
+		name       string
+		steps      []step
+		wantFailed int
+	}{
+		{"in order", []step{{0, 1}, {1, 2}, {2, 3}}, 0},
+		{"out of order waits for the gap", []step{{1, 0}, {2, 0}, {0, 3}}, 0},
+		{"failed row does not block", []step{{0, 1}, {-2, 2}, {2, 3}}, 1},
+		{"failed row after the gap", []step{{-3, 0}, {1, 0}, {0, 3}}, 1},
+		{"repeated row", []step{{0, 1}, {0, 1}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := rows(4)
+			c, err := OpenCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"), "data.csv", data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range tt.steps {
+				if s.row >= 0 {
+					c.Done(data[s.row])
+				} else {
+					c.Fail(data[-s.row-1])
+				}
+				if got := c.LastRow(); got != s.want {
+					t.Fatalf("step %d: LastRow = %d, want %d", i, got, s.want)
+				}
+			}
+			if got := c.Failed(); got != tt.wantFailed {
+				t.Errorf("Failed = %d, want %d", got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestCheckpointUntracked(t *testing.T) {
+	c, err := OpenCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"), "data.csv", rows(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Done(Data{})
+	c.Done(Data{Id: "unknown"})
+	if c.LastRow() != 0 {
+		t.Errorf("LastRow = %d, want 0", c.LastRow())
+	}
+}
+
+func TestCheckpointReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	data := rows(5)
+	c, err := OpenCheckpoint(path, "data.csv", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start("places_v8"); err != nil {
+		t.Fatal(err)
+	}
+	c.Done(data[0])
+	c.Fail(data[1])
+	c.Done(data[2])
+	c.Fail(data[4]) // после отметки: при повторе загрузится заново
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		source      string
+		data        []Data
+		wantLastRow int
+		wantFailed  int
+		wantIndex   string
+	}{
+		{"same source", "data.csv", data, 3, 1, "places_v8"},
+		{"other source", "other.csv", data, 0, 0, ""},
+		{"other row count", "data.csv", rows(6), 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := OpenCheckpoint(path, tt.source, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.LastRow() != tt.wantLastRow || c.Failed() != tt.wantFailed || c.Index() != tt.wantIndex {
+				t.Errorf("reopened %d/%d/%q, want %d/%d/%q", c.LastRow(), c.Failed(), c.Index(), tt.wantLastRow, tt.wantFailed, tt.wantIndex)
+			}
+		})
+	}
+
+	// Start сбрасывает прогресс
+	c, _ = OpenCheckpoint(path, "data.csv", data)
+	if err := c.Start("places_v9"); err != nil {
+		t.Fatal(err)
+	}
+	if c.LastRow() != 0 || c.Failed() != 0 {
+		t.Errorf("after Start %d/%d, want 0/0", c.LastRow(), c.Failed())
+	}
+	if err := c.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove(); err != nil {
+		t.Errorf("second Remove: %v", err)
+	}
+}
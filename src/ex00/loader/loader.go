@@ -0,0 +1,333 @@
+package loader
+
+import (
+	"Day03/ex00/address"
+	"Day03/ex00/phone"
+	"Day03/ex00/translit"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatTSV    Format = "tsv"
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatTSV, FormatCSV, FormatNDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected tsv, csv or ndjson", s)
+}
+
+type Data struct {
+	Id           string          `json:"id"`
+	Name         string          `json:"name"`
+	NameCyr      string          `json:"name_cyr,omitempty"`
+	Address      string          `json:"address"`
+	AddressCyr   string          `json:"address_cyr,omitempty"`
+	AddressParts address.Address `json:"address_parts"`
+	Phone        string          `json:"phone"`
+	PhoneE164    []string        `json:"phone_e164,omitempty"`
+	GroupId      string          `json:"group_id,omitempty"`
+	Location     Location        `json:"location"`
+}
+
+type Location struct {
+	Longitude float64 `json:"lon"`
+	Latitude  float64 `json:"lat"`
+}
+
+// RowError номер строки считается без заголовка, с единицы
+type RowError struct {
+	Row   int    `json:"row"`
+	Id    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// Parse если onError == nil, первая же битая строка прерывает разбор
+func Parse(r io.Reader, format Format, onError func(RowError)) ([]Data, error) {
+	if format == FormatNDJSON {
+		return parseNdjson(r, onError)
+	}
+	comma := '\t'
+	if format == FormatCSV {
+		comma = ','
+	}
+	return parseCsv(r, comma, onError)
+}
+
+func parseCsv(r io.Reader, comma rune, onError func(RowError)) ([]Data, error) {
+	const op = "parseCsv function process"
+	var result []Data
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	_, _ = reader.Read() // пропускаем первую строку
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			var data Data
+			if data, err = MakeData(record); err == nil {
+				result = append(result, data)
+				continue
+			}
+		}
+		if onError == nil {
+			return nil, errors.New(op + ": " + err.Error())
+		}
+		onError(RowError{Row: row, Error: err.Error()})
+	}
+	return result, nil
+}
+
+func parseNdjson(r io.Reader, onError func(RowError)) ([]Data, error) {
+	const op = "parseNdjson function process"
+	var result []Data
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for row := 1; scanner.Scan(); row++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var data Data
+		if err := json.Unmarshal(line, &data); err != nil {
+			if onError == nil {
+				return nil, errors.New(op + ": " + err.Error())
+			}
+			onError(RowError{Row: row, Error: err.Error()})
+			continue
+		}
+		Enrich(&data)
+		result = append(result, data)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	return result, nil
+}
+
+func MakeData(record []string) (Data, error) {
+	const op = "makeData function process"
+	if len(record) != 6 {
+		return Data{}, fmt.Errorf("Invalid person slice: %v", record)
+	}
+	lon, err := strconv.ParseFloat(record[4], 64)
+	if err != nil {
+		return Data{}, errors.New(op + ": " + err.Error())
+	}
+	lat, err := strconv.ParseFloat(record[5], 64)
+	if err != nil {
+		return Data{}, errors.New(op + ": " + err.Error())
+	}
+	data := Data{
+		Id:      record[0],
+		Name:    record[1],
+		Address: record[2],
+		Phone:   record[3],
+		Location: Location{
+			Longitude: lon,
+			Latitude:  lat,
+		},
+	}
+	Enrich(&data)
+	return data, nil
+}
+
+// Enrich поля, которые выводятся из исходных: разобранный адрес и номера в E.164.
+// Нужна и миграции, чтобы документы, загруженные старой версией, получили новые поля
+func Enrich(d *Data) {
+	d.AddressParts = address.Parse(d.Address)
+	d.PhoneE164 = phone.NormalizeAll(d.Phone, phone.DefaultCountry)
+}
+
+// AddCyrillic дополнительно сохраняет название и адрес кириллицей (обратная транслитерация)
+func AddCyrillic(data []Data) {
+	for i := range data {
+		data[i].NameCyr = translit.ToCyrillic(data[i].Name)
+		data[i].AddressCyr = translit.ToCyrillic(data[i].Address)
+	}
+}
+
+type Callbacks struct {
+	OnSuccess func(d Data)
+	OnFailure func(d Data, err error)
+}
+
+func makeConfig(es *elasticsearch.Client, index string, onError func(context.Context, error)) esutil.BulkIndexerConfig {
+	return esutil.BulkIndexerConfig{
+		Index:         index,
+		Client:        es,
+		NumWorkers:    2,
+		FlushBytes:    5000,
+		FlushInterval: time.Second * 30,
+		OnError:       onError,
+	}
+}
+
+// Retry повторы для элементов, на которые эластик ответил 429/5xx, и для пачек, которые до него
+// не дошли; пауза перед n-й попыткой Backoff * 2^(n-1)
+type Retry struct {
+	Max     int
+	Backoff time.Duration
+}
+
+var DefaultRetry = Retry{Max: 5, Backoff: 500 * time.Millisecond}
+
+// ErrRetriesExhausted OnFailure получает его для документов, которые так и не удалось повторить;
+// остальные ошибки в OnFailure окончательные, повтор не поможет
+var ErrRetriesExhausted = errors.New("retries exhausted")
+
+// LoadData шлет документы через BulkIndexer; документы без id получают id от эластика
+func LoadData(ctx context.Context, es *elasticsearch.Client, index string, data []Data, cb Callbacks) error {
+	const op = "loadData function process"
+	pending := data
+	for attempt := 0; ; attempt++ {
+		failed, err := loadRound(ctx, es, index, pending, cb)
+		if err != nil {
+			return errors.New(op + ": " + err.Error())
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+		if attempt == DefaultRetry.Max {
+			if cb.OnFailure != nil {
+				for _, f := range failed {
+					cb.OnFailure(f.data, fmt.Errorf("%w: %s", ErrRetriesExhausted, f.err))
+				}
+			}
+			return fmt.Errorf("%s: %d documents not indexed after %d retries: %s", op, len(failed), attempt, failed[0].err)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.New(op + ": " + ctx.Err().Error())
+		case <-time.After(DefaultRetry.Backoff << attempt):
+		}
+		pending = pending[:0:0]
+		for _, f := range failed {
+			pending = append(pending, f.data)
+		}
+	}
+}
+
+type failedItem struct {
+	data Data
+	err  error
+}
+
+const (
+	itemPending = iota
+	itemDone
+	itemRetry
+)
+
+// loadRound одна попытка; возвращает то, что стоит повторить. Окончательные ошибки
+// (битый документ, 4xx кроме 429) сразу уходят в OnFailure
+func loadRound(ctx context.Context, es *elasticsearch.Client, index string, data []Data, cb Callbacks) ([]failedItem, error) {
+	// ошибки отправки целой пачки (нет связи, 5xx) приходят не в OnFailure элементов, а сюда
+	var flushErr error
+	var flushOnce sync.Once
+	bi, err := esutil.NewBulkIndexer(makeConfig(es, index, func(ctx context.Context, err error) {
+		flushOnce.Do(func() { flushErr = err })
+	}))
+	if err != nil {
+		return nil, err
+	}
+	// каждый элемент пишет только свою ячейку, читаем после bi.Close
+	state := make([]int, len(data))
+	errs := make([]error, len(data))
+	for i, d := range data {
+		i, d := i, d
+		dInfo, err := json.Marshal(d)
+		if err != nil {
+			return nil, errors.New("cannot marshaling data: " + err.Error())
+		}
+		err = bi.Add(
+			ctx,
+			esutil.BulkIndexerItem{
+				Action:     "index",
+				DocumentID: d.Id,
+				Body:       bytes.NewReader(dInfo),
+				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+					state[i] = itemDone
+					if cb.OnSuccess != nil {
+						cb.OnSuccess(d)
+					}
+				},
+				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+					if err == nil {
+						err = fmt.Errorf("%s: %s", res.Error.Type, res.Error.Reason)
+					}
+					if res.Status == http.StatusTooManyRequests || res.Status >= http.StatusInternalServerError {
+						state[i], errs[i] = itemRetry, err
+						return
+					}
+					state[i] = itemDone
+					if cb.OnFailure != nil {
+						cb.OnFailure(d, err)
+					}
+				},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := bi.Close(ctx); err != nil {
+		return nil, err
+	}
+	var failed []failedItem
+	for i, d := range data {
+		switch state[i] {
+		case itemRetry:
+			failed = append(failed, failedItem{data: d, err: errs[i]})
+		case itemPending:
+			err := flushErr
+			if err == nil {
+				err = errors.New("no response from elasticsearch")
+			}
+			failed = append(failed, failedItem{data: d, err: err})
+		}
+	}
+	return failed, nil
+}
+
+// Count документов в индексе, для сверки после загрузки
+func Count(ctx context.Context, es *elasticsearch.Client, index string) (int, error) {
+	const op = "loader.Count"
+	res, err := es.Count(es.Count.WithContext(ctx), es.Count.WithIndex(index))
+	if err != nil {
+		return 0, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return 0, errors.New(op + ": " + res.Status())
+	}
+	var body struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return 0, errors.New(op + ": " + err.Error())
+	}
+	return body.Count, nil
+}
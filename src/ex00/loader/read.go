@@ -0,0 +1,85 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"strconv"
+	"time"
+)
+
+const (
+	readBatch     = 1000
+	readKeepAlive = time.Minute
+)
+
+type scrollPage struct {
+	ScrollId string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			Id     string `json:"_id"`
+			Source Data   `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// ReadAll все документы индекса через scroll, Id берется из _id.
+// Нужна миграции: документы перечитываются, дополняются и пишутся заново
+func ReadAll(ctx context.Context, es *elasticsearch.Client, index string) ([]Data, error) {
+	const op = "loader.ReadAll"
+	page, err := decodeScroll(es.Search(
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(index),
+		es.Search.WithSize(readBatch),
+		es.Search.WithSort("_doc"),
+		es.Search.WithScroll(readKeepAlive),
+	))
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	defer func() {
+		if page.ScrollId == "" {
+			return
+		}
+		res, err := es.ClearScroll(es.ClearScroll.WithScrollID(page.ScrollId))
+		if err == nil {
+			_ = res.Body.Close()
+		}
+	}()
+	var result []Data
+	for len(page.Hits.Hits) > 0 {
+		for _, hit := range page.Hits.Hits {
+			d := hit.Source
+			d.Id = hit.Id
+			result = append(result, d)
+		}
+		body, _ := json.Marshal(map[string]string{"scroll": strconv.Itoa(int(readKeepAlive.Seconds())) + "s", "scroll_id": page.ScrollId})
+		next, err := decodeScroll(es.Scroll(es.Scroll.WithContext(ctx), es.Scroll.WithBody(bytes.NewReader(body))))
+		if err != nil {
+			return nil, errors.New(op + ": " + err.Error())
+		}
+		if next.ScrollId == "" {
+			next.ScrollId = page.ScrollId
+		}
+		page = next
+	}
+	return result, nil
+}
+
+func decodeScroll(res *esapi.Response, err error) (scrollPage, error) {
+	if err != nil {
+		return scrollPage{}, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return scrollPage{}, errors.New(res.String())
+	}
+	var page scrollPage
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		return scrollPage{}, err
+	}
+	return page, nil
+}
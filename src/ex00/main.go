@@ -1,186 +1,299 @@
 package main
 
 import (
-	"bytes"
+	"Day03/ex00/dataset"
+	"Day03/ex00/dedup"
+	"Day03/ex00/input"
+	"Day03/ex00/loader"
+	"Day03/ex00/mapping"
+	"Day03/ex00/phone"
+	"Day03/ex00/validate"
 	"context"
-	"encoding/csv"
-	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v8"
-	"github.com/elastic/go-elasticsearch/v8/esutil"
-	"io"
 	"log"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
-	"time"
 )
 
 const csvFile = "../../materials/data.csv"
-const mappingFile = "./schema.json"
-const indexName = "places"
+const mappingsDir = "./mappings"
+const indexSettingsFile = "./index.json"
+const validationFile = "./validation.json"
+
+// indexName alias набора данных, меняется флагом -dataset
+var indexName = dataset.DefaultName
 
 func main() {
-	es, err := elasticsearch.NewDefaultClient()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	cyrillic := flag.Bool("cyrillic", false, "also store names and addresses transliterated back to Cyrillic")
+	flag.StringVar(&phone.DefaultCountry, "country", phone.DefaultCountry, "default country for phone numbers without a country code")
+	dedupMode := flag.String("dedup", string(dedup.ModeOff), "duplicate handling: off, report, skip, merge or tag")
+	dedupRadius := flag.Float64("dedup-radius", dedup.DefaultRadius, "max distance in meters between duplicates with the same name")
+	dedupReport := flag.String("dedup-report", "", "write the duplicate report to this file instead of stdout")
+	fresh := flag.Bool("fresh", false, "ignore the checkpoint of an interrupted load and start over")
+	flag.IntVar(&loader.DefaultRetry.Max, "retries", loader.DefaultRetry.Max, "retries for documents rejected with 429/5xx")
+	flag.DurationVar(&loader.DefaultRetry.Backoff, "retry-backoff", loader.DefaultRetry.Backoff, "pause before the first retry, doubled after each one")
+	datasetName := flag.String("dataset", "", "dataset from the server's datasets.json, default dataset if empty")
+	datasetsPath := flag.String("datasets", "", "datasets.json of the server, required with -dataset")
+	inputFile := flag.String("input", csvFile, "data file, may be gzip, zstd or zip; - reads stdin")
+	member := flag.String("member", "", "file name or glob inside a zip archive")
+	encodingName := flag.String("encoding", "auto", "input encoding: auto, utf-8, windows-1251, koi8-r...")
+	formatName := flag.String("format", string(loader.FormatTSV), "input format: tsv, csv or ndjson")
+	dry := flag.Bool("dry-run", false, "parse, validate and check the data against the mapping without touching elasticsearch")
+	render := flag.String("render", "", "with -dry-run, write the bulk request body to this file")
+	validationReport := flag.String("validation-report", "", "write the validation report to this file instead of stdout")
+	flag.Parse()
+	mode, err := dedup.ParseMode(*dedupMode)
 	if err != nil {
-		log.Fatal("Error in creating clinet", err)
+		log.Fatal(err)
+	}
+	format, err := loader.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !phone.ValidCountry(phone.DefaultCountry) {
+		log.Fatalf("unknown country %q", phone.DefaultCountry)
+	}
+	validation, err := useDataset(*datasetName, *datasetsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	validator, err := validate.Load(validation)
+	if err != nil {
+		log.Fatal(err)
 	}
 	fmt.Println("parsing files...")
-	data, err := parseCsvFile(csvFile)
+	// в обычном режиме первая битая строка останавливает загрузку, в dry-run собираем все
+	var rowErrors []loader.RowError
+	var onRowError func(loader.RowError)
+	if *dry {
+		onRowError = func(e loader.RowError) { rowErrors = append(rowErrors, e) }
+	}
+	data, err := parseInput(*inputFile, input.Options{Member: *member, Encoding: *encodingName}, format, onRowError)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Printf("parsing files completed\n")
-	mapping, err := readMappingFile(mappingFile)
+	if data, err = validateData(validator, data, *validationReport); err != nil {
+		log.Fatal(err)
+	}
+	// кириллицу считаем после валидации: fix может обрезать название и адрес
+	if *cyrillic {
+		loader.AddCyrillic(data)
+	}
+	if mode != dedup.ModeOff {
+		if data, err = dedupData(data, mode, *dedupRadius, *dedupReport); err != nil {
+			log.Fatal(err)
+		}
+	}
+	settings, err := loadIndexSettings(indexSettingsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	version, err := mapping.Latest(mappingsDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *dry {
+		if err := dryRun(data, rowErrors, version.WithSettings(settings), *render); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	es, err := elasticsearch.NewDefaultClient()
 	if err != nil {
+		log.Fatal("Error in creating clinet", err)
+	}
+	checkpoint, err := loader.OpenCheckpoint(checkpointPath(), *inputFile, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	index, resume := resumeIndex(es, checkpoint, *fresh)
+	if resume > 0 {
+		fmt.Printf("resuming from row %d of %d into %s\n", resume, len(data), index)
+	} else {
+		index = createIndexMapping(es, version.WithSettings(settings))
+		if err := checkpoint.Start(index); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := mapping.PrepareBulk(es, index); err != nil {
 		log.Fatal(err)
 	}
-	createIndexMapping(es, mapping)
-	fmt.Println("loading data...\n")
-	err = loadData(es, data)
+	fmt.Println("loading data...")
+	// отказы прерванного запуска: их строки уже пропущены, но в индекс они не попали
+	failedBefore := checkpoint.Failed()
+	failed, err := loadData(es, data[resume:], checkpoint)
+	if saveErr := checkpoint.Save(); saveErr != nil {
+		log.Println(saveErr)
+	}
 	if err != nil {
+		log.Fatal(err, " (run again to resume)")
+	}
+	fmt.Println("optimizing index...")
+	if err := mapping.FinishBulk(es, index, settings); err != nil {
 		log.Fatal(err)
 	}
+	if err := verifyCount(es, index, len(data)-failedBefore-failed); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkpoint.Remove(); err != nil {
+		log.Println(err)
+	}
 	fmt.Println("done")
 }
 
-func createIndexMapping(es *elasticsearch.Client, mapping string) {
-	if response, err := es.Indices.Delete([]string{indexName},
-		es.Indices.Delete.WithIgnoreUnavailable(true)); err != nil || response.IsError() {
-		log.Fatal("Can't delete index", err) // удаляем если уже существует такой индекс
+// resumeIndex продолжаем, только если индекс из чекпоинта все еще стоит за alias
+func resumeIndex(es *elasticsearch.Client, checkpoint *loader.Checkpoint, fresh bool) (string, int) {
+	if fresh || checkpoint.LastRow() == 0 {
+		return "", 0
 	}
+	live, exists, err := mapping.GetLive(es, indexName)
+	if err != nil || !exists || live.Index != checkpoint.Index() {
+		return "", 0
+	}
+	return live.Index, checkpoint.LastRow()
+}
 
-	res, err := es.Indices.Create(indexName, es.Indices.Create.WithBody(strings.NewReader(mapping)))
+// verifyCount строки, которые эластик окончательно отверг, в ожидаемое число не входят
+func verifyCount(es *elasticsearch.Client, index string, expected int) error {
+	count, err := loader.Count(context.Background(), es, index)
 	if err != nil {
-		log.Fatal("Can't create index", err)
+		return err
 	}
-	if res.IsError() {
-		log.Fatal("Can't create index", res)
+	if count != expected {
+		return fmt.Errorf("index %s has %d documents, expected %d (duplicate ids?)", index, count, expected)
 	}
-	defer func() { _ = res.Body.Close() }()
-}
-
-type Data struct {
-	Id       string   `json:"id"`
-	Name     string   `json:"name"`
-	Address  string   `json:"address"`
-	Phone    string   `json:"phone"`
-	Location location `json:"location"`
+	fmt.Printf("verified: %d documents\n", count)
+	return nil
 }
 
-type location struct {
-	Longitude float64 `json:"lon"`
-	Latitude  float64 `json:"lat"`
+// useDataset берет alias набора из конфига сервера и возвращает файл правил валидации набора
+// (путь в конфиге считается от каталога самого конфига). Без -datasets - только набор по умолчанию
+func useDataset(name string, path string) (string, error) {
+	cfg := dataset.DefaultConfig()
+	if path != "" {
+		var err error
+		if cfg, err = dataset.LoadConfig(path); err != nil {
+			return "", err
+		}
+	} else if name != "" && name != dataset.DefaultName {
+		return "", fmt.Errorf("dataset %q: pass the server's datasets.json with -datasets", name)
+	}
+	d, ok := cfg.Get(name)
+	if !ok {
+		return "", fmt.Errorf("unknown dataset %q, configured: %s", name, strings.Join(cfg.Names(), ", "))
+	}
+	indexName = d.Index
+	if d.Validation == "" {
+		return validationFile, nil
+	}
+	validation := d.Validation
+	if !filepath.IsAbs(validation) {
+		validation = filepath.Join(filepath.Dir(path), validation)
+	}
+	// правила набора указаны явно - молча подставлять московские нельзя
+	if _, err := os.Stat(validation); err != nil {
+		return "", fmt.Errorf("dataset %s: %w", name, err)
+	}
+	return validation, nil
 }
 
-func readMappingFile(path string) (string, error) {
-	const op = "readMappingFile function process"
-	res, err := os.ReadFile(path)
-	if err != nil {
-		return "", errors.New(op + ": " + err.Error())
-	}
-	return string(res), nil
+// checkpointPath у каждого набора свой чекпоинт
+func checkpointPath() string {
+	return "./load_checkpoint_" + indexName + ".json"
 }
 
-func parseCsvFile(path string) ([]Data, error) {
-	const op = "parseCsvFile function process"
-	var result []Data
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, errors.New(op + ": " + err.Error())
-	}
-	defer file.Close()
-	reader := csv.NewReader(file)
-	reader.Comma = '\t'
-	_, _ = reader.Read() // пропускаем первую строку
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
+func validateData(v *validate.Validator, data []loader.Data, reportFile string) ([]loader.Data, error) {
+	const op = "validateData function process"
+	result, report := validate.Data(v, data, nil)
+	out := os.Stdout
+	if reportFile != "" {
+		file, err := os.Create(reportFile)
 		if err != nil {
 			return nil, errors.New(op + ": " + err.Error())
 		}
-		data, err := makeData(record)
+		defer file.Close()
+		out = file
+	}
+	report.Write(out)
+	return result, nil
+}
+
+// dedupData отчет пишем до изменения данных, чтобы в нем были все записи групп
+func dedupData(data []loader.Data, mode dedup.Mode, radius float64, reportFile string) ([]loader.Data, error) {
+	const op = "dedupData function process"
+	groups := dedup.Find(data, radius)
+	out := os.Stdout
+	if reportFile != "" {
+		file, err := os.Create(reportFile)
 		if err != nil {
 			return nil, errors.New(op + ": " + err.Error())
 		}
-		result = append(result, data)
+		defer file.Close()
+		out = file
 	}
+	dedup.WriteReport(out, data, groups)
+	result := dedup.Apply(data, groups, mode)
+	fmt.Printf("dedup (%s): %d -> %d documents\n", mode, len(data), len(result))
 	return result, nil
 }
 
-func makeData(record []string) (Data, error) {
-	const op = "makeData function process"
-	if len(record) != 6 {
-		return Data{}, fmt.Errorf("Invalid person slice: %v", record)
-	}
-	lon, err := strconv.ParseFloat(record[4], 64)
+// createIndexMapping индекс places_vN пересоздается, places становится alias на него
+func createIndexMapping(es *elasticsearch.Client, version mapping.Version) string {
+	index, err := mapping.Recreate(es, indexName, version)
 	if err != nil {
-		return Data{}, errors.New(op + ": " + err.Error())
+		log.Fatal("Can't create index", err)
 	}
-	lat, err := strconv.ParseFloat(record[5], 64)
-	if err != nil {
-		return Data{}, errors.New(op + ": " + err.Error())
-	}
-	return Data{
-		Id:      record[0],
-		Name:    record[1],
-		Address: record[2],
-		Phone:   record[3],
-		Location: location{
-			Longitude: lon,
-			Latitude:  lat,
-		},
-	}, nil
+	fmt.Printf("created %s with mapping %s\n", index, version.File)
+	return index
 }
 
-func makeConfig(es *elasticsearch.Client) esutil.BulkIndexerConfig {
-	return esutil.BulkIndexerConfig{
-		Index:         indexName,
-		Client:        es,
-		NumWorkers:    2,
-		FlushBytes:    5000,
-		FlushInterval: time.Second * 30,
+// loadIndexSettings без index.json используются mapping.DefaultSettings
+func loadIndexSettings(path string) (mapping.Settings, error) {
+	settings, err := mapping.LoadSettings(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return mapping.DefaultSettings(), nil
 	}
+	return settings, err
 }
 
-func loadData(es *elasticsearch.Client, data []Data) error {
-	var placeHolder uint64
-	const op = "loadData function process"
-	bi, err := esutil.NewBulkIndexer(makeConfig(es))
+func parseInput(path string, opts input.Options, format loader.Format, onError func(loader.RowError)) ([]loader.Data, error) {
+	const op = "parseInput function process"
+	src, err := input.Open(path, opts)
 	if err != nil {
-		return errors.New(op + ": " + err.Error())
-	}
-	for _, d := range data {
-		dInfo, err := json.Marshal(d)
-		if err != nil {
-			return errors.New("cannot marshaling data in" + op + ": " + err.Error())
-		}
-		err = bi.Add(
-			context.Background(),
-			esutil.BulkIndexerItem{
-				Action:     "index",
-				DocumentID: d.Id,
-				Body:       bytes.NewReader(dInfo),
-				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-					atomic.AddUint64(&placeHolder, 1)
-				},
-				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-					if err != nil {
-						log.Println("ERROR:", err)
-					} else {
-						log.Printf("ERROR: %s: %s", res.Error.Type, res.Error.Reason)
-					}
-				},
-			},
-		)
-	}
-	err = bi.Close(context.Background())
-	if err != nil {
-		return errors.New(op + ": " + err.Error())
+		return nil, errors.New(op + ": " + err.Error())
 	}
-	return nil
+	defer src.Close()
+	fmt.Printf("input %s: %s\n", path, src)
+	return loader.Parse(src, format, onError)
+}
+
+// loadData возвращает число документов, которые эластик отверг без права на повтор
+func loadData(es *elasticsearch.Client, data []loader.Data, checkpoint *loader.Checkpoint) (int, error) {
+	var failed int64
+	err := loader.LoadData(context.Background(), es, indexName, data, loader.Callbacks{
+		OnSuccess: func(d loader.Data) {
+			checkpoint.Done(d)
+		},
+		OnFailure: func(d loader.Data, err error) {
+			atomic.AddInt64(&failed, 1)
+			log.Println("ERROR:", err)
+			// исчерпавшие повторы строки при следующем запуске пробуем снова
+			if !errors.Is(err, loader.ErrRetriesExhausted) {
+				checkpoint.Fail(d)
+			}
+		},
+	})
+	return int(atomic.LoadInt64(&failed)), err
 }
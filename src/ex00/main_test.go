@@ -0,0 +1,109 @@
+package main
+
+import (
+	"Day03/ex00/loader"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBulk отвечает на _bulk статусом из statuses по id документа, остальным 201
+func fakeBulk(t *testing.T, statuses map[string]int) *elasticsearch.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, "/_bulk") {
+			http.NotFound(w, r)
+			return
+		}
+		var items []interface{}
+		hasErrors := false
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action map[string]struct {
+				Id string `json:"_id"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				t.Error(err)
+				return
+			}
+			scanner.Scan() // тело документа
+			id := action["index"].Id
+			status, ok := statuses[id]
+			if !ok {
+				status = http.StatusCreated
+			}
+			item := map[string]interface{}{"_id": id, "status": status}
+			if status >= 300 {
+				hasErrors = true
+				item["error"] = map[string]string{"type": "test_exception", "reason": "status " + strconv.Itoa(status)}
+			}
+			items = append(items, map[string]interface{}{"index": item})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"took": 1, "errors": hasErrors, "items": items})
+	}))
+	t.Cleanup(srv.Close)
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es
+}
+
+func TestLoadDataCheckpoint(t *testing.T) {
+	retry := loader.DefaultRetry
+	loader.DefaultRetry = loader.Retry{Max: 1, Backoff: time.Millisecond}
+	t.Cleanup(func() { loader.DefaultRetry = retry })
+
+	tests := []struct {
+		name        string
+		statuses    map[string]int
+		wantErr     bool
+		wantFailed  int
+		wantLastRow int
+		wantMarked  int
+	}{
+		{"all indexed", nil, false, 0, 4, 0},
+		{"permanent rejection does not block", map[string]int{"2": http.StatusBadRequest}, false, 1, 4, 1},
+		{"retry exhausted row stays for the next run", map[string]int{"2": http.StatusBadRequest, "3": http.StatusTooManyRequests}, true, 2, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]loader.Data, 4)
+			for i := range data {
+				data[i].Id = strconv.Itoa(i + 1)
+			}
+			path := filepath.Join(t.TempDir(), "checkpoint.json")
+			checkpoint, err := loader.OpenCheckpoint(path, "data.csv", data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			failed, err := loadData(fakeBulk(t, tt.statuses), data, checkpoint)
+			if (err != nil) != tt.wantErr || failed != tt.wantFailed {
+				t.Fatalf("loadData = %d, %v; want %d failed, error %v", failed, err, tt.wantFailed, tt.wantErr)
+			}
+			if err := checkpoint.Save(); err != nil {
+				t.Fatal(err)
+			}
+			// после перезапуска отметка и отказы те же
+			reopened, err := loader.OpenCheckpoint(path, "data.csv", data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := fmt.Sprint(checkpoint.LastRow(), checkpoint.Failed(), reopened.LastRow(), reopened.Failed())
+			want := fmt.Sprint(tt.wantLastRow, tt.wantMarked, tt.wantLastRow, tt.wantMarked)
+			if got != want {
+				t.Errorf("LastRow, Failed before and after reopen = %s, want %s", got, want)
+			}
+		})
+	}
+}
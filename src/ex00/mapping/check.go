@@ -0,0 +1,74 @@
+package mapping
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CheckDocument сверяет документ (как он уйдет в bulk, после json) с маппингом версии.
+// problems - значения, которые эластик не примет; dynamic - поля, которых нет в маппинге:
+// эластик добавит их сам с угаданным типом
+func (v Version) CheckDocument(doc map[string]interface{}) (problems []string, dynamic []string) {
+	c := docChecker{}
+	c.object("", doc, properties(v.Mappings()))
+	return c.problems, c.dynamic
+}
+
+type docChecker struct {
+	problems []string
+	dynamic  []string
+}
+
+func (c *docChecker) object(prefix string, doc map[string]interface{}, props map[string]interface{}) {
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		path := prefix + k
+		field, ok := props[k].(map[string]interface{})
+		if !ok {
+			c.dynamic = append(c.dynamic, path)
+			continue
+		}
+		c.value(path, doc[k], field)
+	}
+}
+
+func (c *docChecker) value(path string, value interface{}, field map[string]interface{}) {
+	if value == nil {
+		return
+	}
+	// массив допустим для любого типа, проверяем элементы
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			c.value(path, item, field)
+		}
+		return
+	}
+	switch t := fieldType(field); t {
+	case "text", "keyword", "search_as_you_type":
+		if _, ok := value.(string); !ok {
+			c.problems = append(c.problems, fmt.Sprintf("%s: expected string for %s, got %T", path, t, value))
+		}
+	case "geo_point":
+		point, ok := value.(map[string]interface{})
+		lat, latOk := point["lat"].(float64)
+		lon, lonOk := point["lon"].(float64)
+		switch {
+		case !ok || !latOk || !lonOk:
+			c.problems = append(c.problems, path+": expected {\"lat\", \"lon\"} for geo_point")
+		case lat < -90 || lat > 90 || lon < -180 || lon > 180:
+			c.problems = append(c.problems, path+": geo_point out of range")
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			c.problems = append(c.problems, fmt.Sprintf("%s: expected object, got %T", path, value))
+			return
+		}
+		props, _ := field["properties"].(map[string]interface{})
+		c.object(path+".", obj, props)
+	}
+}
@@ -0,0 +1,145 @@
+package mapping
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change Breaking - применить можно только пересозданием индекса и reindex
+type Change struct {
+	Path     string
+	Kind     ChangeKind
+	Breaking bool
+	Live     interface{}
+	Desired  interface{}
+}
+
+func (c Change) String() string {
+	mark := " "
+	if c.Breaking {
+		mark = "!"
+	}
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("%s + %s %v", mark, c.Path, c.Desired)
+	case Removed:
+		return fmt.Sprintf("%s - %s %v", mark, c.Path, c.Live)
+	}
+	return fmt.Sprintf("%s ~ %s %v -> %v", mark, c.Path, c.Live, c.Desired)
+}
+
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff сравнивает живой индекс с нужной версией. Новое поле верхнего уровня можно добавить
+// через PUT _mapping, все остальное (смена типа, анализатора, новые multi-fields, которые
+// старые документы не заполнят без переиндексации, настройки анализа) считается breaking
+func Diff(liveMappings, liveAnalysis map[string]interface{}, desired Version) []Change {
+	var changes []Change
+	diffProperties("", properties(liveMappings), properties(desired.Mappings()), false, &changes)
+	if !reflect.DeepEqual(normalize(liveAnalysis), normalize(desired.Analysis())) {
+		changes = append(changes, Change{
+			Path: "settings.analysis", Kind: Changed, Breaking: true,
+			Live: liveAnalysis != nil, Desired: desired.Analysis() != nil,
+		})
+	}
+	return changes
+}
+
+func properties(m map[string]interface{}) map[string]interface{} {
+	p, _ := m["properties"].(map[string]interface{})
+	return p
+}
+
+// diffProperties multi - сравниваем fields внутри поля, там любое добавление breaking
+func diffProperties(prefix string, live, desired map[string]interface{}, multi bool, changes *[]Change) {
+	for _, name := range sortedKeys(live, desired) {
+		path := prefix + name
+		l, inLive := live[name].(map[string]interface{})
+		d, inDesired := desired[name].(map[string]interface{})
+		switch {
+		case !inLive:
+			*changes = append(*changes, Change{Path: path, Kind: Added, Breaking: multi, Desired: fieldType(d)})
+			continue
+		case !inDesired:
+			*changes = append(*changes, Change{Path: path, Kind: Removed, Breaking: true, Live: fieldType(l)})
+			continue
+		}
+		for _, param := range sortedKeys(l, d) {
+			if param == "properties" || param == "fields" {
+				continue
+			}
+			if lv, dv := normalize(l[param]), normalize(d[param]); !reflect.DeepEqual(lv, dv) {
+				*changes = append(*changes, Change{Path: path + "." + param, Kind: Changed, Breaking: true, Live: l[param], Desired: d[param]})
+			}
+		}
+		lp, _ := l["properties"].(map[string]interface{})
+		dp, _ := d["properties"].(map[string]interface{})
+		diffProperties(path+".", lp, dp, multi, changes)
+		lf, _ := l["fields"].(map[string]interface{})
+		df, _ := d["fields"].(map[string]interface{})
+		diffProperties(path+".fields.", lf, df, true, changes)
+	}
+}
+
+// fieldType у объектных полей type не указывается
+func fieldType(field map[string]interface{}) interface{} {
+	if t, ok := field["type"]; ok {
+		return t
+	}
+	return "object"
+}
+
+// normalize эластик отдает настройки строками ("3", "true"), приводим к ним и свои значения
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = normalize(val)
+		}
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func sortedKeys(maps ...map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
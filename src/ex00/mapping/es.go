@@ -0,0 +1,181 @@
+package mapping
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"net/http"
+	"time"
+)
+
+// Live индекс, на который сейчас указывает alias. Старые версии загрузчика создавали
+// индекс с именем самого alias, тогда Index == alias и Version == 0
+type Live struct {
+	Index    string
+	Version  int
+	Mappings map[string]interface{}
+	Analysis map[string]interface{}
+}
+
+// GetLive false, если ни индекса, ни alias еще нет
+func GetLive(es *elasticsearch.Client, alias string) (Live, bool, error) {
+	const op = "mapping.GetLive"
+	res, err := es.Indices.GetMapping(es.Indices.GetMapping.WithIndex(alias))
+	if err != nil {
+		return Live{}, false, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode == http.StatusNotFound {
+		return Live{}, false, nil
+	}
+	if res.IsError() {
+		return Live{}, false, errors.New(op + ": " + res.String())
+	}
+	var mappings map[string]struct {
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&mappings); err != nil {
+		return Live{}, false, errors.New(op + ": " + err.Error())
+	}
+	if len(mappings) != 1 {
+		return Live{}, false, fmt.Errorf("%s: %s points to %d indices, expected one", op, alias, len(mappings))
+	}
+	var live Live
+	for index, m := range mappings {
+		live.Index, live.Mappings = index, m.Mappings
+	}
+	if meta, ok := live.Mappings["_meta"].(map[string]interface{}); ok {
+		if version, ok := meta["version"].(float64); ok {
+			live.Version = int(version)
+		}
+	}
+	if live.Analysis, err = getAnalysis(es, live.Index); err != nil {
+		return Live{}, false, errors.New(op + ": " + err.Error())
+	}
+	return live, true, nil
+}
+
+func getAnalysis(es *elasticsearch.Client, index string) (map[string]interface{}, error) {
+	res, err := es.Indices.GetSettings(es.Indices.GetSettings.WithIndex(index))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return nil, errors.New(res.String())
+	}
+	var settings map[string]struct {
+		Settings struct {
+			Index struct {
+				Analysis map[string]interface{} `json:"analysis"`
+			} `json:"index"`
+		} `json:"settings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&settings); err != nil {
+		return nil, err
+	}
+	return settings[index].Settings.Index.Analysis, nil
+}
+
+// Recreate удаляет все, на что указывает alias, и создает индекс версии v под этим alias
+func Recreate(es *elasticsearch.Client, alias string, v Version) (string, error) {
+	const op = "mapping.Recreate"
+	live, exists, err := GetLive(es, alias)
+	if err != nil {
+		return "", errors.New(op + ": " + err.Error())
+	}
+	if exists {
+		if err := do(es.Indices.Delete([]string{live.Index})); err != nil {
+			return "", errors.New(op + ": " + err.Error())
+		}
+	}
+	index := v.IndexName(alias)
+	if err := do(es.Indices.Delete([]string{index}, es.Indices.Delete.WithIgnoreUnavailable(true))); err != nil {
+		return "", errors.New(op + ": " + err.Error())
+	}
+	if err := create(es, index, v); err != nil {
+		return "", errors.New(op + ": " + err.Error())
+	}
+	err = updateAliases(es, map[string]interface{}{"add": map[string]string{"index": index, "alias": alias}})
+	if err != nil {
+		return "", errors.New(op + ": " + err.Error())
+	}
+	return index, nil
+}
+
+// Copier переписывает документы из индекса from в индекс to (from == to - на месте),
+// заново вычисляя производные поля: _reindex копирует _source как есть,
+// и поля, которые добавила новая версия, у старых документов остались бы пустыми
+type Copier func(from, to string) error
+
+// Migrate без breaking изменений обновляет маппинг на месте и переписывает документы в том же индексе,
+// иначе создает новый индекс, переносит в него документы через copyDocs и атомарно переключает alias.
+// Старый индекс удаляется, если keepOld == false (или если его имя совпадает с alias)
+func Migrate(es *elasticsearch.Client, alias string, live Live, v Version, changes []Change, keepOld bool, copyDocs Copier) (string, error) {
+	const op = "mapping.Migrate"
+	if !HasBreaking(changes) {
+		body, err := v.IndexBody()
+		if err != nil {
+			return "", errors.New(op + ": " + err.Error())
+		}
+		var parsed struct {
+			Mappings json.RawMessage `json:"mappings"`
+		}
+		_ = json.Unmarshal(body, &parsed)
+		if err := do(es.Indices.PutMapping([]string{live.Index}, bytes.NewReader(parsed.Mappings))); err != nil {
+			return "", errors.New(op + ": " + err.Error())
+		}
+		if err := copyDocs(live.Index, live.Index); err != nil {
+			return "", errors.New(op + ": " + err.Error())
+		}
+		return live.Index, nil
+	}
+	index := v.IndexName(alias)
+	if index == live.Index {
+		index += "_" + time.Now().Format("20060102150405")
+	}
+	if err := create(es, index, v); err != nil {
+		return "", errors.New(op + ": " + err.Error())
+	}
+	if err := copyDocs(live.Index, index); err != nil {
+		return "", fmt.Errorf("%s: %s (new index %s left for inspection)", op, err.Error(), index)
+	}
+	actions := []interface{}{map[string]interface{}{"add": map[string]string{"index": index, "alias": alias}}}
+	if keepOld && live.Index != alias {
+		actions = append(actions, map[string]interface{}{"remove": map[string]string{"index": live.Index, "alias": alias}})
+	} else {
+		actions = append(actions, map[string]interface{}{"remove_index": map[string]string{"index": live.Index}})
+	}
+	if err := updateAliases(es, actions...); err != nil {
+		return "", errors.New(op + ": " + err.Error())
+	}
+	return index, nil
+}
+
+func create(es *elasticsearch.Client, index string, v Version) error {
+	body, err := v.IndexBody()
+	if err != nil {
+		return err
+	}
+	return do(es.Indices.Create(index, es.Indices.Create.WithBody(bytes.NewReader(body))))
+}
+
+func updateAliases(es *elasticsearch.Client, actions ...interface{}) error {
+	body, _ := json.Marshal(map[string]interface{}{"actions": actions})
+	return do(es.Indices.UpdateAliases(bytes.NewReader(body)))
+}
+
+// do закрывает тело ответа и превращает ответ с ошибкой в error
+func do(res *esapi.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return errors.New(res.String())
+	}
+	return nil
+}
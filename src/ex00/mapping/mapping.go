@@ -0,0 +1,97 @@
+package mapping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Version файл mappings/NNN_описание.json: тело запроса на создание индекса (settings + mappings)
+type Version struct {
+	Number int
+	File   string
+	Body   map[string]interface{}
+}
+
+var fileRegexp = regexp.MustCompile(`^(\d+)_[\w-]+\.json$`)
+
+// LoadDir версии по возрастанию номера; номера должны быть уникальными
+func LoadDir(dir string) ([]Version, error) {
+	const op = "mapping.LoadDir"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	var versions []Version
+	seen := map[int]string{}
+	for _, entry := range entries {
+		m := fileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(m[1])
+		if prev, ok := seen[number]; ok {
+			return nil, fmt.Errorf("%s: version %d is used by both %s and %s", op, number, prev, entry.Name())
+		}
+		seen[number] = entry.Name()
+		file, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.New(op + ": " + err.Error())
+		}
+		v := Version{Number: number, File: entry.Name()}
+		if err := json.Unmarshal(file, &v.Body); err != nil {
+			return nil, fmt.Errorf("%s: %s: %s", op, entry.Name(), err.Error())
+		}
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return nil, errors.New(op + ": no mapping versions in " + dir)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Number < versions[j].Number })
+	return versions, nil
+}
+
+// Latest последняя версия, ее загрузчик и применяет
+func Latest(dir string) (Version, error) {
+	versions, err := LoadDir(dir)
+	if err != nil {
+		return Version{}, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// IndexName физический индекс версии, alias указывает на него
+func (v Version) IndexName(alias string) string {
+	return fmt.Sprintf("%s_v%d", alias, v.Number)
+}
+
+func (v Version) Mappings() map[string]interface{} {
+	m, _ := v.Body["mappings"].(map[string]interface{})
+	return m
+}
+
+func (v Version) Analysis() map[string]interface{} {
+	settings, _ := v.Body["settings"].(map[string]interface{})
+	analysis, _ := settings["analysis"].(map[string]interface{})
+	return analysis
+}
+
+// IndexBody тело для создания индекса с номером версии в mappings._meta.version
+func (v Version) IndexBody() ([]byte, error) {
+	body := map[string]interface{}{}
+	for k, val := range v.Body {
+		body[k] = val
+	}
+	mappings := map[string]interface{}{}
+	for k, val := range v.Mappings() {
+		mappings[k] = val
+	}
+	mappings["_meta"] = map[string]interface{}{"version": v.Number, "file": v.File}
+	body["mappings"] = mappings
+	return json.Marshal(body)
+}
@@ -0,0 +1,118 @@
+package mapping
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func version(t *testing.T, body string) Version {
+	t.Helper()
+	v := Version{Number: 1, File: "001_test.json"}
+	if err := json.Unmarshal([]byte(body), &v.Body); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+const liveBody = `{
+	"settings": {"analysis": {"analyzer": {"ru": {"type": "custom", "tokenizer": "standard"}}}},
+	"mappings": {"properties": {
+		"name": {"type": "text", "fields": {"raw": {"type": "keyword"}}},
+		"location": {"type": "geo_point"},
+		"address_parts": {"properties": {"city": {"type": "keyword"}}}
+	}}
+}`
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name         string
+		desired      string
+		want         []string
+		wantBreaking bool
+	}{
+		{"same", liveBody, nil, false},
+		{
+			"new top level field",
+			strings.Replace(liveBody, `"location": {"type": "geo_point"},`, `"location": {"type": "geo_point"}, "phone": {"type": "keyword"},`, 1),
+			[]string{"  + phone keyword"}, false,
+		},
+		{
+			"new multi-field",
+			strings.Replace(liveBody, `"raw": {"type": "keyword"}`, `"raw": {"type": "keyword"}, "cyr": {"type": "text"}`, 1),
+			[]string{"! + name.fields.cyr text"}, true,
+		},
+		{
+			"type change",
+			strings.Replace(liveBody, `"city": {"type": "keyword"}`, `"city": {"type": "text"}`, 1),
+			[]string{"! ~ address_parts.city.type keyword -> text"}, true,
+		},
+		{
+			"removed field",
+			strings.Replace(liveBody, `"location": {"type": "geo_point"},`, ``, 1),
+			[]string{"! - location geo_point"}, true,
+		},
+		{
+			"analysis change",
+			strings.Replace(liveBody, `"tokenizer": "standard"`, `"tokenizer": "whitespace"`, 1),
+			[]string{"! ~ settings.analysis true -> true"}, true,
+		},
+	}
+	live := version(t, liveBody)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := Diff(live.Mappings(), live.Analysis(), version(t, tt.desired))
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Diff = %q, want %q", got, tt.want)
+			}
+			if HasBreaking(changes) != tt.wantBreaking {
+				t.Errorf("HasBreaking = %v, want %v", HasBreaking(changes), tt.wantBreaking)
+			}
+		})
+	}
+}
+
+// эластик отдает числа и булевы в настройках строками
+func TestDiffNormalizesValues(t *testing.T) {
+	live := version(t, `{"mappings": {"properties": {"name": {"type": "text", "index": "false"}}}}`)
+	desired := version(t, `{"mappings": {"properties": {"name": {"type": "text", "index": false}}}}`)
+	if changes := Diff(live.Mappings(), live.Analysis(), desired); len(changes) != 0 {
+		t.Errorf("Diff = %v, want no changes", changes)
+	}
+}
+
+func TestCheckDocument(t *testing.T) {
+	tests := []struct {
+		name         string
+		doc          string
+		wantProblems int
+		wantDynamic  []string
+	}{
+		{"valid", `{"name": "Kafe", "location": {"lat": 55.7, "lon": 37.6}, "address_parts": {"city": "Moskva"}}`, 0, nil},
+		{"array of strings", `{"name": ["a", "b"]}`, 0, nil},
+		{"null value", `{"name": null}`, 0, nil},
+		{"number for text", `{"name": 5}`, 1, nil},
+		{"broken geo_point", `{"location": {"lat": 55.7}}`, 1, nil},
+		{"geo_point out of range", `{"location": {"lat": 95, "lon": 37.6}}`, 1, nil},
+		{"string for object", `{"address_parts": "Moskva"}`, 1, nil},
+		{"dynamic fields", `{"group_id": "1", "address_parts": {"street": "Arbat"}}`, 0, []string{"address_parts.street", "group_id"}},
+	}
+	v := version(t, liveBody)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			problems, dynamic := v.CheckDocument(doc)
+			if len(problems) != tt.wantProblems || !reflect.DeepEqual(dynamic, tt.wantDynamic) {
+				t.Errorf("CheckDocument = %q, %q; want %d problems, %q", problems, dynamic, tt.wantProblems, tt.wantDynamic)
+			}
+		})
+	}
+}
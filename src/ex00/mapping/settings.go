@@ -0,0 +1,139 @@
+package mapping
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"os"
+)
+
+// Settings настройки индекса из index.json, накладываются поверх settings из файла версии.
+// Имена полей как у настроек index.* эластика
+type Settings struct {
+	Shards          int                    `json:"number_of_shards,omitempty"`
+	Replicas        *int                   `json:"number_of_replicas,omitempty"`
+	RefreshInterval string                 `json:"refresh_interval,omitempty"`
+	MaxResultWindow int                    `json:"max_result_window,omitempty"`
+	Analysis        map[string]interface{} `json:"analysis,omitempty"`
+}
+
+// DefaultSettings один узел из docker-compose: реплики некуда класть.
+// max_result_window 20000 - постраничный список доходит до ~13700 мест, стандартных 10000 не хватает
+func DefaultSettings() Settings {
+	replicas := 0
+	return Settings{
+		Shards:          1,
+		Replicas:        &replicas,
+		RefreshInterval: "1s",
+		MaxResultWindow: 20000,
+	}
+}
+
+// LoadSettings поля, которых нет в файле, берутся из DefaultSettings
+func LoadSettings(path string) (Settings, error) {
+	const op = "mapping.LoadSettings"
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return Settings{}, fmt.Errorf("%s: %w", op, err)
+	}
+	s := DefaultSettings()
+	if err := json.Unmarshal(file, &s); err != nil {
+		return Settings{}, errors.New(op + ": " + err.Error())
+	}
+	return s, nil
+}
+
+// WithSettings копия версии с настройками s; анализаторы и фильтры из s дополняют
+// и перекрывают одноименные из файла версии
+func (v Version) WithSettings(s Settings) Version {
+	settings := map[string]interface{}{}
+	if base, ok := v.Body["settings"].(map[string]interface{}); ok {
+		for k, val := range base {
+			settings[k] = val
+		}
+	}
+	if s.Shards > 0 {
+		settings["number_of_shards"] = s.Shards
+	}
+	if s.Replicas != nil {
+		settings["number_of_replicas"] = *s.Replicas
+	}
+	if s.RefreshInterval != "" {
+		settings["refresh_interval"] = s.RefreshInterval
+	}
+	if s.MaxResultWindow > 0 {
+		settings["max_result_window"] = s.MaxResultWindow
+	}
+	if len(s.Analysis) > 0 {
+		analysis := map[string]interface{}{}
+		for kind, val := range v.Analysis() {
+			analysis[kind] = val
+		}
+		for kind, val := range s.Analysis {
+			merged := map[string]interface{}{}
+			if base, ok := analysis[kind].(map[string]interface{}); ok {
+				for name, def := range base {
+					merged[name] = def
+				}
+			}
+			if extra, ok := val.(map[string]interface{}); ok {
+				for name, def := range extra {
+					merged[name] = def
+				}
+			}
+			analysis[kind] = merged
+		}
+		settings["analysis"] = analysis
+	}
+	body := map[string]interface{}{}
+	for k, val := range v.Body {
+		body[k] = val
+	}
+	body["settings"] = settings
+	v.Body = body
+	return v
+}
+
+// PrepareBulk на время загрузки отключаем refresh и реплики, так bulk заметно быстрее
+func PrepareBulk(es *elasticsearch.Client, index string) error {
+	const op = "mapping.PrepareBulk"
+	if err := putSettings(es, index, map[string]interface{}{"refresh_interval": "-1", "number_of_replicas": 0}); err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	return nil
+}
+
+// FinishBulk возвращает настройки из s, сливает сегменты в один (индекс после загрузки
+// только читается) и делает refresh, чтобы документы сразу были видны поиску
+func FinishBulk(es *elasticsearch.Client, index string, s Settings) error {
+	const op = "mapping.FinishBulk"
+	// null возвращает значение по умолчанию
+	restore := map[string]interface{}{"refresh_interval": nil, "number_of_replicas": nil}
+	if s.RefreshInterval != "" {
+		restore["refresh_interval"] = s.RefreshInterval
+	}
+	if s.Replicas != nil {
+		restore["number_of_replicas"] = *s.Replicas
+	}
+	if err := putSettings(es, index, restore); err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	err := do(es.Indices.Forcemerge(es.Indices.Forcemerge.WithIndex(index), es.Indices.Forcemerge.WithMaxNumSegments(1)))
+	if err != nil {
+		return errors.New(op + ": forcemerge: " + err.Error())
+	}
+	if err := do(es.Indices.Refresh(es.Indices.Refresh.WithIndex(index))); err != nil {
+		return errors.New(op + ": refresh: " + err.Error())
+	}
+	return nil
+}
+
+func putSettings(es *elasticsearch.Client, index string, settings map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"index": settings})
+	if err != nil {
+		return err
+	}
+	return do(es.Indices.PutSettings(bytes.NewReader(body), es.Indices.PutSettings.WithIndex(index)))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"Day03/ex00/dedup"
+	"Day03/ex00/loader"
+	"Day03/ex00/mapping"
+	"Day03/ex00/translit"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"log"
+	"sync/atomic"
+)
+
+// runMigrate подкоманда: go run . migrate [-dataset spb] [-dry-run] [-keep-old]
+// сравнивает живой маппинг с последней версией из mappings/ и приводит индекс к ней;
+// документы при этом переписываются с заново вычисленными производными полями
+func runMigrate(args []string) error {
+	const op = "runMigrate"
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only print the diff")
+	keepOld := fs.Bool("keep-old", false, "keep the previous index after a reindex migration")
+	datasetName := fs.String("dataset", "", "dataset from the server's datasets.json, default dataset if empty")
+	datasetsPath := fs.String("datasets", "", "datasets.json of the server, required with -dataset")
+	force := fs.Bool("force", false, "migrate even if the live index has a newer mapping version")
+	cyrillic := fs.Bool("cyrillic", false, "add Cyrillic copies to documents that don't have them yet")
+	dedupMode := fs.String("dedup", string(dedup.ModeOff), "off, or tag to recompute duplicate groups")
+	dedupRadius := fs.Float64("dedup-radius", dedup.DefaultRadius, "max distance in meters between duplicates with the same name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// skip и merge удаляют документы, это дело загрузчика, а не миграции
+	mode, err := dedup.ParseMode(*dedupMode)
+	if err != nil {
+		return err
+	}
+	if mode != dedup.ModeOff && mode != dedup.ModeTag {
+		return fmt.Errorf("%s: -dedup %s is not supported by migrate, use off or tag", op, mode)
+	}
+	if _, err := useDataset(*datasetName, *datasetsPath); err != nil {
+		return err
+	}
+	settings, err := loadIndexSettings(indexSettingsFile)
+	if err != nil {
+		return err
+	}
+	desired, err := mapping.Latest(mappingsDir)
+	if err != nil {
+		return err
+	}
+	desired = desired.WithSettings(settings)
+	es, err := elasticsearch.NewDefaultClient()
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	live, exists, err := mapping.GetLive(es, indexName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s: index %s does not exist, run the loader first", op, indexName)
+	}
+	fmt.Printf("live: %s, mapping version %d\ndesired: %s, mapping version %d\n", live.Index, live.Version, desired.File, desired.Number)
+	if live.Version > desired.Number && !*force {
+		return fmt.Errorf("%s: live index is newer than %s, use -force to downgrade", op, desired.File)
+	}
+	changes := mapping.Diff(live.Mappings, live.Analysis, desired)
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if len(changes) == 0 && live.Version == desired.Number {
+		fmt.Println("up to date")
+		return nil
+	}
+	if mapping.HasBreaking(changes) {
+		fmt.Println("breaking changes (!), a reindex into a new index is required")
+	}
+	if *dryRun {
+		return nil
+	}
+	rewrite := rewriter{es: es, settings: settings, cyrillic: *cyrillic, dedup: mode, radius: *dedupRadius}
+	index, err := mapping.Migrate(es, indexName, live, desired, changes, *keepOld, rewrite.copy)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s (mapping version %d)\n", indexName, index, desired.Number)
+	return nil
+}
+
+// rewriter перечитывает документы и дополняет их так же, как загрузчик при разборе
+type rewriter struct {
+	es       *elasticsearch.Client
+	settings mapping.Settings
+	cyrillic bool
+	dedup    dedup.Mode
+	radius   float64
+}
+
+func (rw rewriter) copy(from, to string) error {
+	const op = "rewriter.copy"
+	ctx := context.Background()
+	data, err := loader.ReadAll(ctx, rw.es, from)
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	for i := range data {
+		loader.Enrich(&data[i])
+		// кириллица пересчитывается у тех, у кого она уже была: название могло поменяться
+		if rw.cyrillic || data[i].NameCyr != "" || data[i].AddressCyr != "" {
+			data[i].NameCyr = translit.ToCyrillic(data[i].Name)
+			data[i].AddressCyr = translit.ToCyrillic(data[i].Address)
+		}
+		if rw.dedup == dedup.ModeTag {
+			data[i].GroupId = ""
+		}
+	}
+	if rw.dedup == dedup.ModeTag {
+		data = dedup.Apply(data, dedup.Find(data, rw.radius), dedup.ModeTag)
+	}
+	fmt.Printf("rewriting %d documents %s -> %s\n", len(data), from, to)
+	if err := mapping.PrepareBulk(rw.es, to); err != nil {
+		return err
+	}
+	var failed int64
+	err = loader.LoadData(ctx, rw.es, to, data, loader.Callbacks{
+		OnFailure: func(d loader.Data, err error) {
+			atomic.AddInt64(&failed, 1)
+			log.Println("ERROR:", d.Id, err)
+		},
+	})
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	if n := atomic.LoadInt64(&failed); n > 0 {
+		return fmt.Errorf("%s: %d of %d documents failed", op, n, len(data))
+	}
+	if err := mapping.FinishBulk(rw.es, to, rw.settings); err != nil {
+		return err
+	}
+	return verifyCount(rw.es, to, len(data))
+}
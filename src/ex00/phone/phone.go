@@ -0,0 +1,94 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DefaultCountry страна для номеров без кода, в data.csv все номера московские
+var DefaultCountry = "RU"
+
+type country struct {
+	code        string
+	trunk       string // национальный префикс, который набирают вместо кода страны
+	nationalLen int
+}
+
+var countries = map[string]country{
+	"RU": {code: "7", trunk: "8", nationalLen: 10},
+	"KZ": {code: "7", trunk: "8", nationalLen: 10},
+	"BY": {code: "375", trunk: "80", nationalLen: 9},
+	"UA": {code: "380", trunk: "0", nationalLen: 9},
+	"GB": {code: "44", trunk: "0", nationalLen: 10},
+	"US": {code: "1", trunk: "1", nationalLen: 10},
+}
+
+var ErrInvalid = errors.New("invalid phone number")
+
+func ValidCountry(c string) bool {
+	_, ok := countries[strings.ToUpper(c)]
+	return ok
+}
+
+// Normalize приводит номер к E.164: "(499) 183-14-10" -> "+74991831410"
+func Normalize(raw string, defaultCountry string) (string, error) {
+	c, ok := countries[strings.ToUpper(defaultCountry)]
+	if !ok {
+		return "", fmt.Errorf("unknown country %q", defaultCountry)
+	}
+	raw = strings.TrimSpace(raw)
+	international := strings.HasPrefix(raw, "+")
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || strings.ContainsRune("+()-./", r):
+		default:
+			return "", fmt.Errorf("%w: %q", ErrInvalid, raw)
+		}
+	}
+	digits := b.String()
+	if !international && strings.HasPrefix(digits, "00") {
+		international, digits = true, digits[2:]
+	}
+	switch {
+	case international:
+	case len(digits) == c.nationalLen:
+		digits = c.code + digits
+	case len(digits) == len(c.trunk)+c.nationalLen && strings.HasPrefix(digits, c.trunk):
+		digits = c.code + digits[len(c.trunk):]
+	case len(digits) == len(c.code)+c.nationalLen && strings.HasPrefix(digits, c.code):
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalid, raw)
+	}
+	// E.164 не длиннее 15 цифр
+	if len(digits) < 8 || len(digits) > 15 {
+		return "", fmt.Errorf("%w: %q", ErrInvalid, raw)
+	}
+	return "+" + digits, nil
+}
+
+// Split в поле телефона бывает несколько номеров через ";" или ","
+func Split(raw string) []string {
+	var parts []string
+	for _, part := range strings.FieldsFunc(raw, func(r rune) bool { return r == ';' || r == ',' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// NormalizeAll номера из Split в E.164, нераспознанные пропускаем
+func NormalizeAll(raw string, defaultCountry string) []string {
+	var result []string
+	for _, part := range Split(raw) {
+		if n, err := Normalize(part, defaultCountry); err == nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package phone
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw     string
+		country string
+		want    string
+		wantErr bool
+	}{
+		{"(499) 183-14-10", "RU", "+74991831410", false},
+		{"8 (495) 123-45-67", "RU", "+74951234567", false},
+		{"7 495 123 45 67", "RU", "+74951234567", false},
+		{"+7 (495) 123-45-67", "RU", "+74951234567", false},
+		{"0044 20 7946 0958", "RU", "+442079460958", false},
+		{"+44 20 7946 0958", "ru", "+442079460958", false},
+		{"020 7946 0958", "GB", "+442079460958", false},
+		{"80 29 123 45 67", "BY", "+375291234567", false},
+		{"183-14-10", "RU", "", true},
+		{"8 (495) 123-45-6x", "RU", "", true},
+		{"+1234", "RU", "", true},
+		{"(499) 183-14-10", "XX", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.raw, tt.country)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Normalize(%q, %s) = %q, %v; want %q", tt.raw, tt.country, got, err, tt.want)
+		}
+	}
+	if _, err := Normalize("12", "RU"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("error %v, want ErrInvalid", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"(499) 183-14-10", []string{"(499) 183-14-10"}},
+		{"(499) 183-14-10; (495) 123-45-67", []string{"(499) 183-14-10", "(495) 123-45-67"}},
+		{"(499) 183-14-10,(495) 123-45-67", []string{"(499) 183-14-10", "(495) 123-45-67"}},
+		{" ; , ", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := Split(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeAll(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"(499) 183-14-10; (495) 123-45-67", []string{"+74991831410", "+74951234567"}},
+		{"(499) 183-14-10, не указан", []string{"+74991831410"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := NormalizeAll(tt.raw, "RU"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeAll(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestValidCountry(t *testing.T) {
+	tests := []struct {
+		country string
+		want    bool
+	}{
+		{"RU", true},
+		{"by", true},
+		{"XX", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidCountry(tt.country); got != tt.want {
+			t.Errorf("ValidCountry(%q) = %v, want %v", tt.country, got, tt.want)
+		}
+	}
+}
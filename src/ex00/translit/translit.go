@@ -0,0 +1,82 @@
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// cyrToLat схема транслитерации, которой записаны названия в materials/data.csv.
+// char_filter cyr_to_lat в mappings/ отличается: ь и апострофы он выбрасывает ("bul'var" -> "bulvar"),
+// так сравниваются и "бульвар", и записи, где мягкий знак потеряли
+var cyrToLat = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "jo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "j", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "'", 'э': "e", 'ю': "ju",
+	'я': "ja",
+}
+
+// latToCyr обратная таблица; сочетания букв идут первыми
+var latToCyr = []struct {
+	lat string
+	cyr string
+}{
+	{"sch", "щ"}, {"zh", "ж"}, {"ch", "ч"}, {"sh", "ш"}, {"ts", "ц"}, {"jo", "ё"}, {"ju", "ю"}, {"ja", "я"},
+	{"a", "а"}, {"b", "б"}, {"v", "в"}, {"g", "г"}, {"d", "д"}, {"e", "е"}, {"z", "з"}, {"i", "и"},
+	{"j", "й"}, {"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"}, {"o", "о"}, {"p", "п"}, {"r", "р"},
+	{"s", "с"}, {"t", "т"}, {"u", "у"}, {"f", "ф"}, {"h", "х"}, {"y", "ы"}, {"'", "ь"},
+	{"c", "к"}, {"w", "в"}, {"x", "кс"}, {"q", "к"},
+}
+
+func ToLatin(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		lat, ok := cyrToLat[unicode.ToLower(r)]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		if unicode.IsUpper(r) {
+			lat = capitalize(lat)
+		}
+		b.WriteString(lat)
+	}
+	return b.String()
+}
+
+// ToCyrillic восстанавливает кириллицу из транслита; однозначно это не сделать
+// ("ts" может быть и "ц", и "тс"), поэтому результат годится для поиска, но не для показа
+func ToCyrillic(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		rest := strings.ToLower(string(runes[i:min(i+3, len(runes))]))
+		matched := false
+		for _, m := range latToCyr {
+			if !strings.HasPrefix(rest, m.lat) {
+				continue
+			}
+			cyr := m.cyr
+			if unicode.IsUpper(runes[i]) {
+				cyr = capitalize(cyr)
+			}
+			b.WriteString(cyr)
+			i += len([]rune(m.lat))
+			matched = true
+			break
+		}
+		if !matched {
+			b.WriteRune(runes[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+func capitalize(s string) string {
+	r := []rune(s)
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
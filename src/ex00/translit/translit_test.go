@@ -0,0 +1,53 @@
+package translit
+
+import "testing"
+
+func TestToLatin(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Тверская", "Tverskaja"},
+		{"Щукинская", "Schukinskaja"},
+		{"Ёлка", "Jolka"},
+		{"Подъезд", "Podezd"},
+		{"бульвар", "bul'var"},
+		{"Кафе 24", "Kafe 24"},
+		{"Cafe", "Cafe"},
+	}
+	for _, tt := range tests {
+		if got := ToLatin(tt.in); got != tt.want {
+			t.Errorf("ToLatin(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToCyrillic(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Tverskaja", "Тверская"},
+		{"Shokoladnitsa", "Шоколадница"},
+		{"Schukinskaja", "Щукинская"},
+		{"bul'var", "бульвар"},
+		{"Jolka", "Ёлка"},
+		{"Coffee", "Коффее"},
+		{"Kafe 24", "Кафе 24"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ToCyrillic(tt.in); got != tt.want {
+			t.Errorf("ToCyrillic(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// после ToLatin кириллица без ъ восстанавливается как была
+func TestRoundTrip(t *testing.T) {
+	for _, s := range []string{"Шоколадница", "Пятёрочка", "улица Петровка", "Малая Бронная"} {
+		if got := ToCyrillic(ToLatin(s)); got != s {
+			t.Errorf("ToCyrillic(ToLatin(%q)) = %q", s, got)
+		}
+	}
+}
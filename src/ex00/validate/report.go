@@ -0,0 +1,91 @@
+package validate
+
+import (
+	"Day03/ex00/address"
+	"Day03/ex00/loader"
+	"Day03/ex00/phone"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// maxReportIssues в отчете храним только первые проблемы, счетчики считают все
+const maxReportIssues = 1000
+
+type RowIssue struct {
+	Id string `json:"id,omitempty"`
+	Issue
+}
+
+type Report struct {
+	Checked  int            `json:"checked"`
+	Rejected int            `json:"rejected"`
+	Fixed    int            `json:"fixed"`
+	Warned   int            `json:"warned"`
+	ByRule   map[string]int `json:"by_rule"`
+	Issues   []RowIssue     `json:"issues"`
+}
+
+// Data проверяет записи загрузчика; отброшенные передаются в onReject (может быть nil)
+func Data(v *Validator, data []loader.Data, onReject func(d loader.Data, issues []Issue)) ([]loader.Data, Report) {
+	report := Report{ByRule: map[string]int{}}
+	result := make([]loader.Data, 0, len(data))
+	for _, d := range data {
+		report.Checked++
+		r := Record{Name: d.Name, Address: d.Address, Phone: d.Phone, Lat: d.Location.Latitude, Lon: d.Location.Longitude}
+		issues, rejected := v.Check(&r)
+		report.add(d.Id, issues, rejected)
+		if rejected {
+			if onReject != nil {
+				onReject(d, issues)
+			}
+			continue
+		}
+		if r.Address != d.Address {
+			d.AddressParts = address.Parse(r.Address)
+		}
+		if r.Phone != d.Phone {
+			d.PhoneE164 = phone.NormalizeAll(r.Phone, phone.DefaultCountry)
+		}
+		d.Name, d.Address, d.Phone = r.Name, r.Address, r.Phone
+		d.Location = loader.Location{Latitude: r.Lat, Longitude: r.Lon}
+		result = append(result, d)
+	}
+	return result, report
+}
+
+func (r *Report) add(id string, issues []Issue, rejected bool) {
+	fixed, warned := false, false
+	for _, issue := range issues {
+		r.ByRule[issue.Rule]++
+		fixed = fixed || issue.Severity == SeverityFix
+		warned = warned || issue.Severity == SeverityWarn
+		if len(r.Issues) < maxReportIssues {
+			r.Issues = append(r.Issues, RowIssue{Id: id, Issue: issue})
+		}
+	}
+	switch {
+	case rejected:
+		r.Rejected++
+	case fixed:
+		r.Fixed++
+	case warned:
+		r.Warned++
+	}
+}
+
+func (r Report) Write(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "validation: %d checked, %d rejected, %d fixed, %d with warnings\n", r.Checked, r.Rejected, r.Fixed, r.Warned)
+	rules := make([]string, 0, len(r.ByRule))
+	for rule := range r.ByRule {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		_, _ = fmt.Fprintf(w, "\t%s: %d\n", rule, r.ByRule[rule])
+	}
+	for _, issue := range r.Issues {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", issue.Id, strings.ToUpper(string(issue.Severity)), issue.Message)
+	}
+}
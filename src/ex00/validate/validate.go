@@ -0,0 +1,229 @@
+package validate
+
+import (
+	"Day03/ex00/phone"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type Severity string
+
+const (
+	SeverityOff    Severity = "off"
+	SeverityWarn   Severity = "warn"   // пишем в отчет, запись оставляем как есть
+	SeverityFix    Severity = "fix"    // исправляем, если правило умеет, иначе как reject
+	SeverityReject Severity = "reject" // запись не индексируем
+)
+
+const (
+	RuleRequired    = "required"    // обязательные поля не пустые
+	RuleLength      = "length"      // fix обрезает до max_length
+	RulePhone       = "phone"       // fix выкидывает номера, не подходящие под phone_pattern
+	RuleCoordinates = "coordinates" // 0/0 и координаты вне допустимого диапазона
+	RuleSwapped     = "swapped"     // lat и lon перепутаны местами, fix меняет их обратно; нужны bounds
+	RuleBounds      = "bounds"      // точка вне ожидаемого города
+)
+
+// Bounds прямоугольник, в котором ожидаем все места
+type Bounds struct {
+	MinLat float64 `json:"min_lat"`
+	MaxLat float64 `json:"max_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+func (b Bounds) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+type Config struct {
+	Rules        map[string]Severity `json:"rules"`
+	Required     []string            `json:"required"`
+	MaxLength    int                 `json:"max_length"`
+	PhonePattern string              `json:"phone_pattern"`
+	Bounds       *Bounds             `json:"bounds,omitempty"`
+}
+
+// DefaultConfig границы Москвы с Зеленоградом и Новой Москвой
+func DefaultConfig() Config {
+	return Config{
+		Rules: map[string]Severity{
+			RuleRequired:    SeverityReject,
+			RuleLength:      SeverityReject,
+			RulePhone:       SeverityWarn,
+			RuleCoordinates: SeverityReject,
+			RuleSwapped:     SeverityFix,
+			RuleBounds:      SeverityWarn,
+		},
+		Required:     []string{"name", "address"},
+		MaxLength:    500,
+		PhonePattern: `^\+?[0-9()\-\s]{5,20}$`,
+		Bounds:       &Bounds{MinLat: 54.9, MaxLat: 56.2, MinLon: 36.5, MaxLon: 38.5},
+	}
+}
+
+// LoadConfig правила, не упомянутые в файле, берутся из DefaultConfig
+func LoadConfig(path string) (Config, error) {
+	const op = "validate.LoadConfig"
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return Config{}, errors.New(op + ": " + err.Error())
+	}
+	return cfg, nil
+}
+
+// Load валидатор по правилам из файла; без файла работают правила по умолчанию
+func Load(path string) (*Validator, error) {
+	cfg, err := LoadConfig(path)
+	if errors.Is(err, os.ErrNotExist) {
+		cfg, err = DefaultConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+// Record поля места, которые проверяем; Check правит его на месте
+type Record struct {
+	Name    string
+	Address string
+	Phone   string
+	Lat     float64
+	Lon     float64
+}
+
+type Issue struct {
+	Rule     string   `json:"rule"`
+	Field    string   `json:"field"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s (%s, %s)", i.Message, i.Rule, i.Severity)
+}
+
+type Validator struct {
+	cfg   Config
+	phone *regexp.Regexp
+}
+
+func New(cfg Config) (*Validator, error) {
+	const op = "validate.New"
+	for rule, severity := range cfg.Rules {
+		switch severity {
+		case SeverityOff, SeverityWarn, SeverityFix, SeverityReject:
+		default:
+			return nil, fmt.Errorf("%s: rule %s: unknown severity %q", op, rule, severity)
+		}
+	}
+	for _, field := range cfg.Required {
+		if field != "name" && field != "address" && field != "phone" {
+			return nil, fmt.Errorf("%s: unknown required field %q", op, field)
+		}
+	}
+	v := &Validator{cfg: cfg}
+	if cfg.PhonePattern != "" {
+		re, err := regexp.Compile(cfg.PhonePattern)
+		if err != nil {
+			return nil, errors.New(op + ": phone_pattern: " + err.Error())
+		}
+		v.phone = re
+	}
+	return v, nil
+}
+
+func (v *Validator) severity(rule string) Severity {
+	if s, ok := v.cfg.Rules[rule]; ok {
+		return s
+	}
+	return SeverityOff
+}
+
+// Check возвращает найденные проблемы и признак того, что запись надо отбросить.
+// Для fix severity в отчете остается fix, если исправить получилось, и reject, если нет
+func (v *Validator) Check(r *Record) ([]Issue, bool) {
+	c := checker{v: v}
+	fields := map[string]*string{"name": &r.Name, "address": &r.Address, "phone": &r.Phone}
+
+	for _, field := range v.cfg.Required {
+		if strings.TrimSpace(*fields[field]) == "" {
+			c.add(RuleRequired, field, field+" is required", false)
+		}
+	}
+	if v.cfg.MaxLength > 0 {
+		for _, field := range []string{"name", "address", "phone"} {
+			if utf8.RuneCountInString(*fields[field]) <= v.cfg.MaxLength {
+				continue
+			}
+			fixed := c.add(RuleLength, field, fmt.Sprintf("%s is longer than %d characters", field, v.cfg.MaxLength), true)
+			if fixed {
+				*fields[field] = string([]rune(*fields[field])[:v.cfg.MaxLength])
+			}
+		}
+	}
+	if v.phone != nil && r.Phone != "" {
+		var good []string
+		bad := false
+		for _, part := range phone.Split(r.Phone) {
+			if v.phone.MatchString(part) {
+				good = append(good, part)
+			} else {
+				bad = true
+			}
+		}
+		if bad && c.add(RulePhone, "phone", fmt.Sprintf("phone %q does not match the expected format", r.Phone), true) {
+			r.Phone = strings.Join(good, ";")
+		}
+	}
+
+	switch {
+	case r.Lat == 0 && r.Lon == 0:
+		c.add(RuleCoordinates, "location", "location is 0,0", false)
+	case r.Lat < -90 || r.Lat > 90 || r.Lon < -180 || r.Lon > 180:
+		c.add(RuleCoordinates, "location", fmt.Sprintf("location %v,%v is out of range", r.Lat, r.Lon), false)
+	case v.cfg.Bounds != nil:
+		b := *v.cfg.Bounds
+		if !b.Contains(r.Lat, r.Lon) && b.Contains(r.Lon, r.Lat) {
+			if c.add(RuleSwapped, "location", fmt.Sprintf("lat and lon look swapped: %v,%v", r.Lat, r.Lon), true) {
+				r.Lat, r.Lon = r.Lon, r.Lat
+			}
+		}
+		if !b.Contains(r.Lat, r.Lon) {
+			c.add(RuleBounds, "location", fmt.Sprintf("location %v,%v is outside the expected area", r.Lat, r.Lon), false)
+		}
+	}
+	return c.issues, c.rejected
+}
+
+type checker struct {
+	v        *Validator
+	issues   []Issue
+	rejected bool
+}
+
+// add возвращает true, если вызывающий должен применить исправление
+func (c *checker) add(rule, field, message string, fixable bool) bool {
+	severity := c.v.severity(rule)
+	if severity == SeverityOff {
+		return false
+	}
+	if severity == SeverityFix && !fixable {
+		severity = SeverityReject
+	}
+	if severity == SeverityReject {
+		c.rejected = true
+	}
+	c.issues = append(c.issues, Issue{Rule: rule, Field: field, Severity: severity, Message: message})
+	return severity == SeverityFix
+}
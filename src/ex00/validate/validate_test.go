@@ -0,0 +1,100 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func validator(t *testing.T, rules map[string]Severity) *Validator {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.MaxLength = 20
+	for rule, severity := range rules {
+		cfg.Rules[rule] = severity
+	}
+	v, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestCheck(t *testing.T) {
+	good := Record{Name: "Kafe", Address: "Petrovka 1", Phone: "(495) 123-45-67", Lat: 55.76, Lon: 37.61}
+	tests := []struct {
+		name         string
+		rules        map[string]Severity
+		edit         func(r *Record)
+		wantRules    []string
+		wantRejected bool
+		want         func(r Record) bool
+	}{
+		{"valid", nil, func(r *Record) {}, nil, false, nil},
+		{"required", nil, func(r *Record) { r.Name = "  " }, []string{RuleRequired}, true, nil},
+		{"required off", map[string]Severity{RuleRequired: SeverityOff}, func(r *Record) { r.Name = "" }, nil, false, nil},
+		{"length reject", nil, func(r *Record) { r.Name = "Очень длинное название" }, []string{RuleLength}, true, nil},
+		{
+			"length fix truncates runes", map[string]Severity{RuleLength: SeverityFix},
+			func(r *Record) { r.Name = "Очень длинное название" }, []string{RuleLength}, false,
+			func(r Record) bool { return r.Name == "Очень длинное назван" },
+		},
+		{"phone warn", nil, func(r *Record) { r.Phone = "звоните" }, []string{RulePhone}, false, nil},
+		{
+			"phone fix keeps good numbers", map[string]Severity{RulePhone: SeverityFix},
+			func(r *Record) { r.Phone = "(495) 1234567,нет" }, []string{RulePhone}, false,
+			func(r Record) bool { return r.Phone == "(495) 1234567" },
+		},
+		{"zero coordinates", nil, func(r *Record) { r.Lat, r.Lon = 0, 0 }, []string{RuleCoordinates}, true, nil},
+		{"out of range", nil, func(r *Record) { r.Lat = 91 }, []string{RuleCoordinates}, true, nil},
+		{
+			"swapped fix", nil,
+			func(r *Record) { r.Lat, r.Lon = 37.61, 55.76 }, []string{RuleSwapped}, false,
+			func(r Record) bool { return r.Lat == 55.76 && r.Lon == 37.61 },
+		},
+		{
+			"swapped warn leaves the point outside", map[string]Severity{RuleSwapped: SeverityWarn},
+			func(r *Record) { r.Lat, r.Lon = 37.61, 55.76 }, []string{RuleSwapped, RuleBounds}, false,
+			func(r Record) bool { return r.Lat == 37.61 },
+		},
+		{"outside bounds", nil, func(r *Record) { r.Lat, r.Lon = 59.93, 30.31 }, []string{RuleBounds}, false, nil},
+		{"fix without fixer rejects", map[string]Severity{RuleBounds: SeverityFix}, func(r *Record) { r.Lat, r.Lon = 59.93, 30.31 }, []string{RuleBounds}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := good
+			tt.edit(&r)
+			issues, rejected := validator(t, tt.rules).Check(&r)
+			var rules []string
+			for _, i := range issues {
+				rules = append(rules, i.Rule)
+			}
+			if strings.Join(rules, ",") != strings.Join(tt.wantRules, ",") || rejected != tt.wantRejected {
+				t.Fatalf("Check = %v, %v; want %v, %v", issues, rejected, tt.wantRules, tt.wantRejected)
+			}
+			if tt.want != nil && !tt.want(r) {
+				t.Errorf("record after fix: %+v", r)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(c *Config)
+		wantErr bool
+	}{
+		{"default", func(c *Config) {}, false},
+		{"unknown severity", func(c *Config) { c.Rules[RulePhone] = "drop" }, true},
+		{"unknown required field", func(c *Config) { c.Required = []string{"email"} }, true},
+		{"bad phone pattern", func(c *Config) { c.PhonePattern = "(" }, true},
+		{"no phone pattern", func(c *Config) { c.PhonePattern = "" }, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		tt.edit(&cfg)
+		if _, err := New(cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: New = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"Day03/ex00/loader"
+	"Day03/ex00/validate"
+	"Day03/ex04/jobs"
+	"Day03/ex04/middleware/auth"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxUploadSize  = 64 << 20
+	maxRunningJobs = 2
+)
+
+var importJobs = jobs.NewManager(maxRunningJobs)
+
+// HandlerApiBulkPlaces принимает файл в теле запроса или в поле file multipart-формы.
+// Формат берется из ?format=, иначе из Content-Type, по умолчанию tsv как materials/data.csv
+func HandlerApiBulkPlaces(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiBulkPlaces"
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, contentType, err := spoolUpload(r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := uploadFormat(r.URL.Query().Get("format"), contentType)
+	if err != nil {
+		removeUpload(file)
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	st, v := store(r), validatorFor(r)
+	job, err := importJobs.Start(auth.Subject(r.Context()), func(job *jobs.Job) error {
+		defer removeUpload(file)
+		// Total - все строки файла, чтобы неразобранные входили в него так же, как в Failed
+		parseErrors := 0
+		data, err := loader.Parse(file, format, func(e loader.RowError) {
+			parseErrors++
+			job.AddError(e)
+		})
+		if err != nil {
+			return err
+		}
+		job.SetTotal(len(data) + parseErrors)
+		data, report := validate.Data(v, data, func(d loader.Data, issues []validate.Issue) {
+			job.AddError(loader.RowError{Id: d.Id, Error: issuesText(issues)})
+		})
+		job.SetValidation(report)
+		loader.AddCyrillic(data)
+		return st.BulkLoad(context.Background(), data, loader.Callbacks{
+			OnSuccess: func(d loader.Data) {
+				job.AddIndexed()
+			},
+			OnFailure: func(d loader.Data, err error) {
+				job.AddError(loader.RowError{Id: d.Id, Error: err.Error()})
+			},
+		})
+	})
+	if errors.Is(err, jobs.ErrBusy) {
+		removeUpload(file)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, op+": "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.Id())
+	writeJson(w, http.StatusAccepted, job.Snapshot())
+}
+
+// HandlerApiGetJob чужая задача выглядит как несуществующая
+func HandlerApiGetJob(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiGetJob"
+	job, ok := importJobs.Get(r.PathValue("id"))
+	if !ok || job.Owner() != auth.Subject(r.Context()) {
+		http.Error(w, op+": job not found", http.StatusNotFound)
+		return
+	}
+	writeJson(w, http.StatusOK, job.Snapshot())
+}
+
+// spoolUpload копирует файл из запроса во временный файл: разбор идет уже после ответа клиенту,
+// а держать в памяти каждую загрузку целиком незачем
+func spoolUpload(r *http.Request) (*os.File, string, error) {
+	src, contentType, err := uploadReader(r)
+	if err != nil {
+		return nil, "", err
+	}
+	file, err := os.CreateTemp("", "bulk-*")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = io.Copy(file, src); err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		removeUpload(file)
+		return nil, "", err
+	}
+	return file, contentType, nil
+}
+
+// uploadReader тело запроса или поле file multipart-формы, без буферизации формы
+func uploadReader(r *http.Request) (io.Reader, string, error) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "multipart/form-data" {
+		return r.Body, contentType, nil
+	}
+	form, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			return nil, "", errors.New("no file field in the form")
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() == "file" {
+			return part, part.Header.Get("Content-Type"), nil
+		}
+	}
+}
+
+func removeUpload(file *os.File) {
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+}
+
+func uploadFormat(query string, contentType string) (loader.Format, error) {
+	if query != "" {
+		return loader.ParseFormat(query)
+	}
+	switch contentType {
+	case "text/csv":
+		return loader.FormatCSV, nil
+	case "application/x-ndjson", "application/ndjson":
+		return loader.FormatNDJSON, nil
+	case "", "text/tab-separated-values", "text/plain", "application/octet-stream", "application/x-www-form-urlencoded":
+		return loader.FormatTSV, nil
+	}
+	return "", errors.New("unsupported content type " + strconv.Quote(contentType))
+}
+
+func issuesText(issues []validate.Issue) string {
+	text := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		text = append(text, issue.String())
+	}
+	return strings.Join(text, "; ")
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[int](2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a is missing")
+	}
+	c.Set("c", 3)
+	tests := []struct {
+		key  string
+		want int
+		ok   bool
+	}{
+		{"a", 1, true},
+		{"b", 0, false},
+		{"c", 3, true},
+	}
+	for _, tt := range tests {
+		got, ok := c.Get(tt.key)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Get(%q) = %d, %v; want %d, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCacheSetOverwrites(t *testing.T) {
+	c := New[string](2, time.Minute)
+	c.Set("a", "old")
+	c.Set("a", "new")
+	c.Set("b", "b")
+	if got, ok := c.Get("a"); !ok || got != "new" {
+		t.Errorf("Get(a) = %q, %v; want new, true", got, ok)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	c := New[int](10, 20*time.Millisecond)
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a expired too early")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a did not expire")
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	c := New[int](10, time.Minute)
+	c.Set("a", 1)
+	c.Purge()
+	if _, ok := c.Get("a"); ok {
+		t.Error("a survived Purge")
+	}
+}
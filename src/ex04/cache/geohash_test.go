@@ -0,0 +1,33 @@
+package cache
+
+import "testing"
+
+func TestGeohash(t *testing.T) {
+	tests := []struct {
+		name      string
+		lat, lon  float64
+		precision int
+		want      string
+	}{
+		{"wikipedia example", 57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{"origin", 0, 0, 5, "s0000"},
+		{"south west corner", -90, -180, 5, "00000"},
+		{"north east corner", 90, 180, 5, "zzzzz"},
+		{"shorter hash is a prefix", 57.64911, 10.40744, 4, "u4pr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Geohash(tt.lat, tt.lon, tt.precision); got != tt.want {
+				t.Errorf("Geohash(%v, %v, %d) = %q, want %q", tt.lat, tt.lon, tt.precision, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeohashNeighboursShareCell(t *testing.T) {
+	a := Geohash(55.75580, 37.61730, 6)
+	b := Geohash(55.75585, 37.61735, 6)
+	if a != b {
+		t.Errorf("points 6 m apart got different cells %q and %q", a, b)
+	}
+}
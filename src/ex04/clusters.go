@@ -0,0 +1,71 @@
+package main
+
+import (
+	"Day03/ex04/types"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxZoom = 29
+	// clusterZoomOffset каждая тайла текущего зума делится на 4x4 ячейки
+	clusterZoomOffset = 2
+)
+
+type ClustersResponse struct {
+	Zoom     int             `json:"zoom"`
+	Total    int             `json:"total"`
+	Clusters []types.Cluster `json:"clusters"`
+}
+
+// HandlerApiClusters ?zoom=12&bbox=west,south,east,north (порядок как в GeoJSON bbox)
+func HandlerApiClusters(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiClusters"
+	zoom, err := strconv.Atoi(r.URL.Query().Get("zoom"))
+	if err != nil || zoom < 0 || zoom > maxZoom {
+		http.Error(w, op+": invalid 'zoom' value, expected integer from 0 to 29", http.StatusBadRequest)
+		return
+	}
+	box := types.BoundingBox{
+		TopLeft:     types.Location{Lat: 90, Long: -180},
+		BottomRight: types.Location{Lat: -90, Long: 180},
+	}
+	if bbox := r.URL.Query().Get("bbox"); bbox != "" {
+		if box, err = parseBbox(bbox); err != nil {
+			http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	clusters, err := store(r).GetClusters(box, min(zoom+clusterZoomOffset, maxZoom))
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := ClustersResponse{Zoom: zoom, Clusters: clusters}
+	for _, c := range clusters {
+		res.Total += c.Count
+	}
+	writeJson(w, http.StatusOK, res)
+}
+
+func parseBbox(s string) (types.BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return types.BoundingBox{}, errors.New("invalid 'bbox' value, expected west,south,east,north")
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return types.BoundingBox{}, errors.New("invalid 'bbox' value: " + err.Error())
+		}
+		v[i] = f
+	}
+	box := types.BoundingBox{
+		TopLeft:     types.Location{Long: v[0], Lat: v[3]},
+		BottomRight: types.Location{Long: v[2], Lat: v[1]},
+	}
+	return box, box.Validate()
+}
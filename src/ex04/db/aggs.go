@@ -0,0 +1,97 @@
+package db
+
+import (
+	"Day03/ex04/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+const maxClusters = 10000
+
+type aggQuery struct {
+	Size  int                    `json:"size"`
+	Query map[string]interface{} `json:"query,omitempty"`
+	Aggs  map[string]interface{} `json:"aggs"`
+}
+
+type centroidAggregation struct {
+	Location types.Location `json:"location"`
+	Count    int            `json:"count"`
+}
+
+type gridBucket struct {
+	Key      string              `json:"key"`
+	DocCount int                 `json:"doc_count"`
+	Centroid centroidAggregation `json:"centroid"`
+}
+
+type gridAggregation struct {
+	Buckets []gridBucket `json:"buckets"`
+}
+
+// aggregate выполняет запрос без хитов и раскладывает aggregations в typed структуру out
+func (s *ElasticSearchStore) aggregate(op string, query aggQuery, out interface{}) error {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	req := esapi.SearchRequest{
+		Index: []string{s.Index},
+		Body:  bytes.NewReader(body),
+	}
+	res, err := req.Do(context.Background(), s.Es)
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return errors.New(op + ": " + res.Status())
+	}
+	resBody := struct {
+		Aggregations interface{} `json:"aggregations"`
+	}{Aggregations: out}
+	if err := json.NewDecoder(res.Body).Decode(&resBody); err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	return nil
+}
+
+// GetClusters группирует места в ячейки geotile_grid заданной точности внутри box
+func (s *ElasticSearchStore) GetClusters(box types.BoundingBox, precision int) ([]types.Cluster, error) {
+	const op = "ElasticSearchStore.GetClusters"
+	var aggs struct {
+		Cells gridAggregation `json:"cells"`
+	}
+	err := s.aggregate(op, aggQuery{
+		Size: 0,
+		Query: map[string]interface{}{
+			"geo_bounding_box": map[string]interface{}{"location": box},
+		},
+		Aggs: map[string]interface{}{
+			"cells": map[string]interface{}{
+				"geotile_grid": map[string]interface{}{
+					"field":     "location",
+					"precision": precision,
+					"size":      maxClusters,
+					"bounds":    box,
+				},
+				"aggs": map[string]interface{}{
+					"centroid": map[string]interface{}{
+						"geo_centroid": map[string]interface{}{"field": "location"},
+					},
+				},
+			},
+		},
+	}, &aggs)
+	if err != nil {
+		return nil, err
+	}
+	clusters := make([]types.Cluster, 0, len(aggs.Cells.Buckets))
+	for _, b := range aggs.Cells.Buckets {
+		clusters = append(clusters, types.Cluster{Key: b.Key, Count: b.DocCount, Centroid: b.Centroid.Location})
+	}
+	return clusters, nil
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"Day03/ex00/loader"
+	"Day03/ex04/cache"
+	"Day03/ex04/types"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheCapacity    = 1024
+	cacheTTL         = 10 * time.Minute
+	versionCheckTime = 10 * time.Second
+	GeohashPrecision = 7 // ячейка ~150x150 м
+)
+
+type placesPage struct {
+	places []types.Place
+	total  int
+	facets map[string][]types.FacetBucket
+}
+
+// CachedStore кэширует ответы эластика, пока индекс places не пересоздан
+type CachedStore struct {
+	*ElasticSearchStore
+	places  *cache.Cache[placesPage]
+	closest *cache.Cache[[]types.Place]
+	tiles   *cache.Cache[[]byte]
+	suggest *cache.Cache[[]types.Suggestion]
+
+	mu         sync.Mutex
+	version    string
+	generation int // растет при каждом Invalidate, чтобы менялся ETag
+	modified   time.Time
+	checked    time.Time
+}
+
+func NewCachedStore(store *ElasticSearchStore) *CachedStore {
+	return &CachedStore{
+		ElasticSearchStore: store,
+		places:             cache.New[placesPage](cacheCapacity, cacheTTL),
+		closest:            cache.New[[]types.Place](cacheCapacity, cacheTTL),
+		tiles:              cache.New[[]byte](cacheCapacity, cacheTTL),
+		suggest:            cache.New[[]types.Suggestion](cacheCapacity, cacheTTL),
+	}
+}
+
+func (s *CachedStore) GetPlaces(limit int, offset int) ([]types.Place, int, error) {
+	s.checkVersion()
+	key := fmt.Sprintf("%d:%d", limit, offset)
+	if page, ok := s.places.Get(key); ok {
+		return page.places, page.total, nil
+	}
+	places, total, err := s.ElasticSearchStore.GetPlaces(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.places.Set(key, placesPage{places: places, total: total})
+	return places, total, nil
+}
+
+func (s *CachedStore) SearchPlaces(filter types.PlaceFilter, sort types.PlaceSort, limit int, offset int) ([]types.Place, int, map[string][]types.FacetBucket, error) {
+	s.checkVersion()
+	key := fmt.Sprintf("search:%d:%d:%s:%s", limit, offset, filter.Key(), sort.Key())
+	if page, ok := s.places.Get(key); ok {
+		return page.places, page.total, page.facets, nil
+	}
+	places, total, facets, err := s.ElasticSearchStore.SearchPlaces(filter, sort, limit, offset)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	s.places.Set(key, placesPage{places: places, total: total, facets: facets})
+	return places, total, facets, nil
+}
+
+func (s *CachedStore) GetClosest(lat, lon float64) ([]types.Place, error) {
+	s.checkVersion()
+	key := cache.Geohash(lat, lon, GeohashPrecision)
+	if places, ok := s.closest.Get(key); ok {
+		return places, nil
+	}
+	places, err := s.ElasticSearchStore.GetClosest(lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	s.closest.Set(key, places)
+	return places, nil
+}
+
+func (s *CachedStore) GetTile(z, x, y int) ([]byte, error) {
+	s.checkVersion()
+	key := fmt.Sprintf("%d/%d/%d", z, x, y)
+	if tile, ok := s.tiles.Get(key); ok {
+		return tile, nil
+	}
+	tile, err := s.ElasticSearchStore.GetTile(z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	s.tiles.Set(key, tile)
+	return tile, nil
+}
+
+// Suggest подсказки дергаются на каждое нажатие клавиши, поэтому кэшируем и их
+func (s *CachedStore) Suggest(prefix string, size int) ([]types.Suggestion, error) {
+	s.checkVersion()
+	key := fmt.Sprintf("%d:%s", size, strings.ToLower(prefix))
+	if suggestions, ok := s.suggest.Get(key); ok {
+		return suggestions, nil
+	}
+	suggestions, err := s.ElasticSearchStore.Suggest(prefix, size)
+	if err != nil {
+		return nil, err
+	}
+	s.suggest.Set(key, suggestions)
+	return suggestions, nil
+}
+
+func (s *CachedStore) IndexVersion() (string, time.Time, error) {
+	s.checkVersion()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("%s-%d", s.version, s.generation), s.modified, nil
+}
+
+// Invalidate сбрасывает кэш, например после изменения данных через api
+func (s *CachedStore) Invalidate() {
+	s.mu.Lock()
+	s.generation++
+	s.modified = time.Now()
+	s.mu.Unlock()
+	s.places.Purge()
+	s.closest.Purge()
+	s.tiles.Purge()
+	s.suggest.Purge()
+}
+
+// checkVersion не чаще раза в versionCheckTime спрашивает у эластика uuid индекса
+func (s *CachedStore) checkVersion() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.checked) < versionCheckTime {
+		return
+	}
+	s.checked = time.Now()
+	version, modified, err := s.ElasticSearchStore.IndexVersion()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if version != s.version {
+		s.places.Purge()
+		s.closest.Purge()
+		s.tiles.Purge()
+		s.suggest.Purge()
+		s.version = version
+		s.modified = modified
+	}
+}
+
+func (s *CachedStore) CreatePlace(place types.PlaceDoc) (string, types.DocVersion, error) {
+	id, version, err := s.ElasticSearchStore.CreatePlace(place)
+	if err == nil {
+		s.Invalidate()
+	}
+	return id, version, err
+}
+
+func (s *CachedStore) UpdatePlace(id string, place types.PlaceDoc, version types.DocVersion) (types.DocVersion, error) {
+	newVersion, err := s.ElasticSearchStore.UpdatePlace(id, place, version)
+	if err == nil {
+		s.Invalidate()
+	}
+	return newVersion, err
+}
+
+func (s *CachedStore) DeletePlace(id string, version *types.DocVersion) error {
+	err := s.ElasticSearchStore.DeletePlace(id, version)
+	if err == nil {
+		s.Invalidate()
+	}
+	return err
+}
+
+func (s *CachedStore) BulkLoad(ctx context.Context, data []loader.Data, cb loader.Callbacks) error {
+	defer s.Invalidate()
+	return s.ElasticSearchStore.BulkLoad(ctx, data, cb)
+}
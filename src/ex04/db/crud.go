@@ -0,0 +1,140 @@
+package db
+
+import (
+	"Day03/ex00/address"
+	"Day03/ex00/loader"
+	"Day03/ex00/phone"
+	"Day03/ex00/translit"
+	"Day03/ex04/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"net/http"
+)
+
+var (
+	ErrNotFound = errors.New("place not found")
+	ErrConflict = errors.New("version conflict")
+)
+
+type writeResponse struct {
+	Id string `json:"_id"`
+	types.DocVersion
+}
+
+// checkWriteResponse переводит 404/409 эластика в ErrNotFound/ErrConflict
+func checkWriteResponse(op string, res *esapi.Response) (writeResponse, error) {
+	var wr writeResponse
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return wr, fmt.Errorf("%s: %w", op, ErrNotFound)
+	case res.StatusCode == http.StatusConflict:
+		return wr, fmt.Errorf("%s: %w", op, ErrConflict)
+	case res.IsError():
+		return wr, errors.New(op + ": " + res.Status())
+	}
+	if err := json.NewDecoder(res.Body).Decode(&wr); err != nil {
+		return wr, errors.New(op + ": " + err.Error())
+	}
+	return wr, nil
+}
+
+// withDerived разбираем адрес и телефон и добавляем кириллицу так же, как загрузчик,
+// чтобы работали фильтры, поиск по номеру и поиск кириллицей
+func withDerived(place types.PlaceDoc) types.PlaceDoc {
+	parts := address.Parse(place.Address)
+	place.AddressParts = &parts
+	place.PhoneE164 = phone.NormalizeAll(place.Phone, phone.DefaultCountry)
+	place.NameCyr = translit.ToCyrillic(place.Name)
+	place.AddressCyr = translit.ToCyrillic(place.Address)
+	return place
+}
+
+func (s *ElasticSearchStore) CreatePlace(place types.PlaceDoc) (string, types.DocVersion, error) {
+	const op = "ElasticSearchStore.CreatePlace"
+	body, err := json.Marshal(withDerived(place))
+	if err != nil {
+		return "", types.DocVersion{}, errors.New(op + ": " + err.Error())
+	}
+	res, err := s.Es.Index(s.Index, bytes.NewReader(body),
+		s.Es.Index.WithOpType("create"),
+		s.Es.Index.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return "", types.DocVersion{}, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	wr, err := checkWriteResponse(op, res)
+	return wr.Id, wr.DocVersion, err
+}
+
+func (s *ElasticSearchStore) GetPlace(id string) (types.PlaceDoc, types.DocVersion, error) {
+	const op = "ElasticSearchStore.GetPlace"
+	res, err := s.Es.Get(s.Index, id)
+	if err != nil {
+		return types.PlaceDoc{}, types.DocVersion{}, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode == http.StatusNotFound {
+		return types.PlaceDoc{}, types.DocVersion{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	if res.IsError() {
+		return types.PlaceDoc{}, types.DocVersion{}, errors.New(op + ": " + res.Status())
+	}
+	var resBody struct {
+		types.DocVersion
+		Source types.PlaceDoc `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resBody); err != nil {
+		return types.PlaceDoc{}, types.DocVersion{}, errors.New(op + ": " + err.Error())
+	}
+	return resBody.Source, resBody.DocVersion, nil
+}
+
+// UpdatePlace перезаписывает документ; version обязателен, чтобы не затереть чужие изменения
+func (s *ElasticSearchStore) UpdatePlace(id string, place types.PlaceDoc, version types.DocVersion) (types.DocVersion, error) {
+	const op = "ElasticSearchStore.UpdatePlace"
+	body, err := json.Marshal(withDerived(place))
+	if err != nil {
+		return types.DocVersion{}, errors.New(op + ": " + err.Error())
+	}
+	res, err := s.Es.Index(s.Index, bytes.NewReader(body),
+		s.Es.Index.WithDocumentID(id),
+		s.Es.Index.WithIfSeqNo(int(version.SeqNo)),
+		s.Es.Index.WithIfPrimaryTerm(int(version.PrimaryTerm)),
+		s.Es.Index.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return types.DocVersion{}, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	wr, err := checkWriteResponse(op, res)
+	return wr.DocVersion, err
+}
+
+// DeletePlace если version == nil, удаляет без проверки версии
+func (s *ElasticSearchStore) DeletePlace(id string, version *types.DocVersion) error {
+	const op = "ElasticSearchStore.DeletePlace"
+	opts := []func(*esapi.DeleteRequest){s.Es.Delete.WithRefresh("wait_for")}
+	if version != nil {
+		opts = append(opts,
+			s.Es.Delete.WithIfSeqNo(int(version.SeqNo)),
+			s.Es.Delete.WithIfPrimaryTerm(int(version.PrimaryTerm)),
+		)
+	}
+	res, err := s.Es.Delete(s.Index, id, opts...)
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	_, err = checkWriteResponse(op, res)
+	return err
+}
+
+// BulkLoad тот же путь через BulkIndexer, что и у загрузчика ex00
+func (s *ElasticSearchStore) BulkLoad(ctx context.Context, data []loader.Data, cb loader.Callbacks) error {
+	return loader.LoadData(ctx, s.Es, s.Index, data, cb)
+}
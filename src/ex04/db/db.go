@@ -1,40 +1,56 @@
 package db
 
 import (
-	"Day03/ex03/types"
+	"Day03/ex04/types"
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
+const indexName = "places"
+
+// ClosestCount сколько мест отдает GetClosest; кандидатов берем с запасом,
+// чтобы после схлопывания дубликатов (одинаковый group_id) их хватило
+const (
+	ClosestCount      = 3
+	closestCandidates = 15
+)
+
+// ElasticSearchStore Index - индекс или alias набора данных, с которым работает хранилище
 type ElasticSearchStore struct {
-	Es *elasticsearch.Client
+	Es    *elasticsearch.Client
+	Index string
+}
+
+// WithIndex хранилище другого набора данных на том же клиенте
+func (s *ElasticSearchStore) WithIndex(index string) *ElasticSearchStore {
+	return &ElasticSearchStore{Es: s.Es, Index: index}
 }
 
 func (s *ElasticSearchStore) GetClosest(lat, lon float64) ([]types.Place, error) {
 	const op = "GetClosest"
 	query1 := types.NewQuery(lat, lon)
+	query1.Size = closestCandidates
 	queryJson1, err := json.Marshal(query1)
-	fmt.Println(string(queryJson1))
 	if err != nil {
 		return nil, errors.New(op + ": " + err.Error())
 	}
 
 	req := esapi.SearchRequest{
-		Index:          []string{"places"},
+		Index:          []string{s.Index},
 		Body:           strings.NewReader(string(queryJson1)),
 		TrackTotalHits: false,
 	}
-	fmt.Println("Sending request")
 	res, err := req.Do(context.Background(), s.Es)
 	if err != nil {
 		return nil, errors.New(op + "ReqDo" + ": " + err.Error())
 	}
-	fmt.Println(res)
 	defer func() { _ = res.Body.Close() }()
 	if res.IsError() {
 		return nil, errors.New(op + " Response error: " + res.Status())
@@ -43,22 +59,27 @@ func (s *ElasticSearchStore) GetClosest(lat, lon float64) ([]types.Place, error)
 	if err := json.NewDecoder(res.Body).Decode(&resBody); err != nil {
 		return nil, errors.New(op + "Decoding " + ": " + err.Error())
 	}
-	places := make([]types.Place, 0)
 	hits := resBody["hits"].(map[string]interface{})["hits"].([]interface{})
-	for _, hit := range hits {
-		source := hit.(map[string]interface{})["_source"]
-		placeBytes, err := json.Marshal(source)
-		if err != nil {
-			continue
+	return collapseGroups(parseHits(hits), ClosestCount), nil
+}
+
+// collapseGroups из мест с одним group_id оставляем ближайшее, места без группы не трогаем
+func collapseGroups(places []types.Place, limit int) []types.Place {
+	seen := map[string]bool{}
+	result := make([]types.Place, 0, limit)
+	for _, place := range places {
+		if len(result) == limit {
+			break
 		}
-		var place types.Place
-		if err := json.Unmarshal(placeBytes, &place); err != nil {
-			continue
+		if place.GroupId != "" {
+			if seen[place.GroupId] {
+				continue
+			}
+			seen[place.GroupId] = true
 		}
-		places = append(places, place)
+		result = append(result, place)
 	}
-
-	return places, nil
+	return result
 }
 
 func (s *ElasticSearchStore) GetPlaces(limit int, offset int) ([]types.Place, int, error) {
@@ -72,9 +93,8 @@ func (s *ElasticSearchStore) GetPlaces(limit int, offset int) ([]types.Place, in
 	if err != nil {
 		return nil, 0, errors.New(op + ": " + err.Error())
 	}
-	fmt.Printf("Query JSON: %s\n", string(queryJson))
 	req := esapi.SearchRequest{
-		Index:          []string{"places"},
+		Index:          []string{s.Index},
 		Body:           strings.NewReader(string(queryJson)),
 		TrackTotalHits: true,
 	}
@@ -91,8 +111,14 @@ func (s *ElasticSearchStore) GetPlaces(limit int, offset int) ([]types.Place, in
 		return nil, 0, errors.New(op + ": " + err.Error())
 	}
 	hits := resBody["hits"].(map[string]interface{})["hits"].([]interface{})
-	places := make([]types.Place, 0, len(hits))
+	places := parseHits(hits)
+	totalHits := int(resBody["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
+	return places, totalHits, nil
+}
 
+// parseHits id берем из _id, а не из _source: у документов, созданных через api, его там нет
+func parseHits(hits []interface{}) []types.Place {
+	places := make([]types.Place, 0, len(hits))
 	for _, hit := range hits {
 		source := hit.(map[string]interface{})["_source"]
 		placeBytes, err := json.Marshal(source)
@@ -103,10 +129,53 @@ func (s *ElasticSearchStore) GetPlaces(limit int, offset int) ([]types.Place, in
 		if err := json.Unmarshal(placeBytes, &place); err != nil {
 			continue
 		}
+		place.Id, _ = hit.(map[string]interface{})["_id"].(string)
 		places = append(places, place)
 	}
-	totalHits := int(resBody["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
-	return places, totalHits, nil
+	return places
+}
+
+// IndexVersion uuid индекса, на который указывает places, и время его создания.
+// Загрузчик пересоздает индекс, поэтому смена uuid значит, что данные поменялись
+func (s *ElasticSearchStore) IndexVersion() (string, time.Time, error) {
+	const op = "ElasticSearchStore.IndexVersion"
+	res, err := s.Es.Indices.GetSettings(
+		s.Es.Indices.GetSettings.WithIndex(s.Index),
+		s.Es.Indices.GetSettings.WithName("index.uuid", "index.creation_date"),
+	)
+	if err != nil {
+		return "", time.Time{}, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return "", time.Time{}, errors.New(op + ": " + res.Status())
+	}
+	var resBody map[string]struct {
+		Settings struct {
+			Index struct {
+				Uuid         string `json:"uuid"`
+				CreationDate string `json:"creation_date"`
+			} `json:"index"`
+		} `json:"settings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resBody); err != nil {
+		return "", time.Time{}, errors.New(op + ": " + err.Error())
+	}
+	names := make([]string, 0, len(resBody))
+	for name := range resBody {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var version []string
+	var modified time.Time
+	for _, name := range names {
+		index := resBody[name].Settings.Index
+		version = append(version, index.Uuid)
+		if ms, err := strconv.ParseInt(index.CreationDate, 10, 64); err == nil && time.UnixMilli(ms).After(modified) {
+			modified = time.UnixMilli(ms)
+		}
+	}
+	return strings.Join(version, ","), modified, nil
 }
 
 func NewElasticSearchStore() (*ElasticSearchStore, error) {
@@ -115,5 +184,5 @@ func NewElasticSearchStore() (*ElasticSearchStore, error) {
 	if err != nil {
 		return nil, errors.New(op + ": " + err.Error())
 	}
-	return &ElasticSearchStore{es}, nil
+	return &ElasticSearchStore{Es: es, Index: indexName}, nil
 }
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"Day03/ex04/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"strings"
+)
+
+const facetSize = 20
+
+// facetFields имя фасета в ответе -> keyword поле
+var facetFields = map[string]string{
+	"city":        "address_parts.city.keyword",
+	"district":    "address_parts.district.keyword",
+	"street_type": "address_parts.street_type.keyword",
+	"street":      "address_parts.street.keyword",
+}
+
+type termsAggregation struct {
+	Buckets []struct {
+		Key      string `json:"key"`
+		DocCount int    `json:"doc_count"`
+	} `json:"buckets"`
+}
+
+func filterQuery(filter types.PlaceFilter) map[string]interface{} {
+	fields := filter.Fields()
+	terms := make([]interface{}, 0, len(fields)+3)
+	for field, value := range fields {
+		terms = append(terms, map[string]interface{}{"term": map[string]string{field: value}})
+	}
+	if filter.NamePrefix != "" {
+		terms = append(terms, map[string]interface{}{"prefix": map[string]interface{}{
+			"name.keyword": map[string]interface{}{"value": strings.ToLower(filter.NamePrefix), "case_insensitive": true},
+		}})
+	}
+	if filter.AddressContains != "" {
+		terms = append(terms, map[string]interface{}{"wildcard": map[string]interface{}{
+			"address.keyword": map[string]interface{}{
+				"value":            "*" + wildcardEscaper.Replace(strings.ToLower(filter.AddressContains)) + "*",
+				"case_insensitive": true,
+			},
+		}})
+	}
+	// телефон есть, если хотя бы один номер удалось привести к E.164
+	var mustNot []interface{}
+	if filter.HasPhone != nil {
+		exists := map[string]interface{}{"exists": map[string]string{"field": "phone_e164"}}
+		if *filter.HasPhone {
+			terms = append(terms, exists)
+		} else {
+			mustNot = append(mustNot, exists)
+		}
+	}
+	if len(terms) == 0 && len(mustNot) == 0 {
+		return nil
+	}
+	query := map[string]interface{}{"filter": terms}
+	if len(mustNot) > 0 {
+		query["must_not"] = mustNot
+	}
+	return map[string]interface{}{"bool": query}
+}
+
+// wildcardEscaper подстрока адреса ищется буквально, * и ? из запроса не работают как шаблон
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`)
+
+// sortClause пустая сортировка - порядок индекса, как было раньше
+func sortClause(sort types.PlaceSort) []interface{} {
+	switch sort.By {
+	case types.SortName:
+		return []interface{}{map[string]string{"name.keyword": "asc"}}
+	case types.SortNameDesc:
+		return []interface{}{map[string]string{"name.keyword": "desc"}}
+	case types.SortDistance:
+		var s types.Sort
+		s.SetGeo(sort.Origin.Lat, sort.Origin.Long)
+		return []interface{}{s}
+	}
+	return nil
+}
+
+// searchPlacesQuery без фильтров query не передаем: "query": null эластик отвергает
+func searchPlacesQuery(filter types.PlaceFilter, sort types.PlaceSort, limit int, offset int) map[string]interface{} {
+	aggs := make(map[string]interface{}, len(facetFields))
+	for name, field := range facetFields {
+		aggs[name] = map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": facetSize}}
+	}
+	query := map[string]interface{}{
+		"size": limit,
+		"from": offset,
+		"aggs": aggs,
+	}
+	if q := filterQuery(filter); q != nil {
+		query["query"] = q
+	}
+	if clause := sortClause(sort); clause != nil {
+		query["sort"] = clause
+	}
+	return query
+}
+
+// SearchPlaces страница мест по фильтрам в заданном порядке и количество мест по значениям фасетов
+func (s *ElasticSearchStore) SearchPlaces(filter types.PlaceFilter, sort types.PlaceSort, limit int, offset int) ([]types.Place, int, map[string][]types.FacetBucket, error) {
+	const op = "ElasticSearchStore.SearchPlaces"
+	body, err := json.Marshal(searchPlacesQuery(filter, sort, limit, offset))
+	if err != nil {
+		return nil, 0, nil, errors.New(op + ": " + err.Error())
+	}
+	req := esapi.SearchRequest{
+		Index:          []string{s.Index},
+		Body:           bytes.NewReader(body),
+		TrackTotalHits: true,
+	}
+	res, err := req.Do(context.Background(), s.Es)
+	if err != nil {
+		return nil, 0, nil, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return nil, 0, nil, errors.New(op + ": " + res.Status())
+	}
+	var resBody struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []interface{} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]termsAggregation `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resBody); err != nil {
+		return nil, 0, nil, errors.New(op + ": " + err.Error())
+	}
+	facets := make(map[string][]types.FacetBucket, len(resBody.Aggregations))
+	for name, agg := range resBody.Aggregations {
+		buckets := make([]types.FacetBucket, 0, len(agg.Buckets))
+		for _, b := range agg.Buckets {
+			buckets = append(buckets, types.FacetBucket{Value: b.Key, Count: b.DocCount})
+		}
+		facets[name] = buckets
+	}
+	return parseHits(resBody.Hits.Hits), resBody.Hits.Total.Value, facets, nil
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"Day03/ex04/types"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func toJson(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSortClause(t *testing.T) {
+	tests := []struct {
+		sort types.PlaceSort
+		want string
+	}{
+		{types.PlaceSort{}, `null`},
+		{types.PlaceSort{By: types.SortName}, `[{"name.keyword":"asc"}]`},
+		{types.PlaceSort{By: types.SortNameDesc}, `[{"name.keyword":"desc"}]`},
+	}
+	for _, tt := range tests {
+		if got := toJson(t, sortClause(tt.sort)); got != tt.want {
+			t.Errorf("sortClause(%q) = %s, want %s", tt.sort.By, got, tt.want)
+		}
+	}
+	got := toJson(t, sortClause(types.PlaceSort{By: types.SortDistance, Origin: types.Location{Lat: 55.75, Long: 37.62}}))
+	for _, part := range []string{`"_geo_distance"`, `"lat":55.75`, `"lon":37.62`, `"order":"asc"`} {
+		if !strings.Contains(got, part) {
+			t.Errorf("distance sort %s has no %s", got, part)
+		}
+	}
+}
+
+func TestSearchPlacesQuery(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name      string
+		filter    types.PlaceFilter
+		sort      types.PlaceSort
+		wantQuery bool
+		wantSort  bool
+	}{
+		{"no filters", types.PlaceFilter{}, types.PlaceSort{}, false, false},
+		{"filter", types.PlaceFilter{HasPhone: &yes}, types.PlaceSort{}, true, false},
+		{"sort only", types.PlaceFilter{}, types.PlaceSort{By: types.SortName}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			if err := json.Unmarshal([]byte(toJson(t, searchPlacesQuery(tt.filter, tt.sort, 10, 20))), &body); err != nil {
+				t.Fatal(err)
+			}
+			query, hasQuery := body["query"]
+			_, hasSort := body["sort"]
+			if hasQuery != tt.wantQuery || query == nil && hasQuery || hasSort != tt.wantSort {
+				t.Errorf("body %v: query %v, sort %v; want %v, %v", body, hasQuery, hasSort, tt.wantQuery, tt.wantSort)
+			}
+			if body["size"] != float64(10) || body["from"] != float64(20) || body["aggs"] == nil {
+				t.Errorf("body %v: wrong size, from or aggs", body)
+			}
+		})
+	}
+}
+
+func TestFilterQuery(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name   string
+		filter types.PlaceFilter
+		want   string
+	}{
+		{"city", types.PlaceFilter{City: "Moskva"}, `{"bool":{"filter":[{"term":{"address_parts.city.keyword":"Moskva"}}]}}`},
+		{
+			"name prefix is lower cased", types.PlaceFilter{NamePrefix: "Kafe"},
+			`{"bool":{"filter":[{"prefix":{"name.keyword":{"case_insensitive":true,"value":"kafe"}}}]}}`,
+		},
+		{
+			"address wildcards are escaped", types.PlaceFilter{AddressContains: `Dom*1?\`},
+			`{"bool":{"filter":[{"wildcard":{"address.keyword":{"case_insensitive":true,"value":"*dom\\*1\\?\\\\*"}}}]}}`,
+		},
+		{"has phone", types.PlaceFilter{HasPhone: &yes}, `{"bool":{"filter":[{"exists":{"field":"phone_e164"}}]}}`},
+		{"no phone", types.PlaceFilter{HasPhone: &no}, `{"bool":{"filter":[],"must_not":[{"exists":{"field":"phone_e164"}}]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toJson(t, filterQuery(tt.filter)); got != tt.want {
+				t.Errorf("filterQuery =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+	all := filterQuery(types.PlaceFilter{City: "Moskva", District: "Zelenograd", Street: "Arbat", NamePrefix: "k", HasPhone: &yes})
+	if n := len(all["bool"].(map[string]interface{})["filter"].([]interface{})); n != 5 {
+		t.Errorf("combined filter has %d clauses, want 5", n)
+	}
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"Day03/ex04/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+type pagedQuery struct {
+	Size  int                    `json:"size"`
+	From  int                    `json:"from"`
+	Query map[string]interface{} `json:"query,omitempty"`
+}
+
+// searchPage общий для поисковых методов: запрос с пагинацией и точным total
+func (s *ElasticSearchStore) searchPage(op string, query pagedQuery) ([]types.Place, int, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, 0, errors.New(op + ": " + err.Error())
+	}
+	req := esapi.SearchRequest{
+		Index:          []string{s.Index},
+		Body:           bytes.NewReader(body),
+		TrackTotalHits: true,
+	}
+	res, err := req.Do(context.Background(), s.Es)
+	if err != nil {
+		return nil, 0, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return nil, 0, errors.New(op + ": " + res.Status())
+	}
+	var resBody map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&resBody); err != nil {
+		return nil, 0, errors.New(op + ": " + err.Error())
+	}
+	hits := resBody["hits"].(map[string]interface{})["hits"].([]interface{})
+	totalHits := int(resBody["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
+	return parseHits(hits), totalHits, nil
+}
+
+func (s *ElasticSearchStore) GetInBoundingBox(box types.BoundingBox, limit int, offset int) ([]types.Place, int, error) {
+	return s.searchPage("ElasticSearchStore.GetInBoundingBox", pagedQuery{
+		Size: limit,
+		From: offset,
+		Query: map[string]interface{}{
+			"geo_bounding_box": map[string]interface{}{
+				"location": box,
+			},
+		},
+	})
+}
+
+// GetInShape geo_shape запрос работает и по полю geo_point, менять маппинг не нужно
+func (s *ElasticSearchStore) GetInShape(shape types.Geometry, limit int, offset int) ([]types.Place, int, error) {
+	return s.searchPage("ElasticSearchStore.GetInShape", pagedQuery{
+		Size: limit,
+		From: offset,
+		Query: map[string]interface{}{
+			"geo_shape": map[string]interface{}{
+				"location": map[string]interface{}{
+					"shape":    shape,
+					"relation": "intersects",
+				},
+			},
+		},
+	})
+}
+
+func (s *ElasticSearchStore) SearchText(q string, limit int, offset int) ([]types.Place, int, error) {
+	return s.searchPage("ElasticSearchStore.SearchText", pagedQuery{
+		Size:  limit,
+		From:  offset,
+		Query: searchQuery(q),
+	})
+}
+
+// GetByPhone номер уже в E.164, ищем точным совпадением по keyword полю
+func (s *ElasticSearchStore) GetByPhone(e164 string, limit int, offset int) ([]types.Place, int, error) {
+	return s.searchPage("ElasticSearchStore.GetByPhone", pagedQuery{
+		Size: limit,
+		From: offset,
+		Query: map[string]interface{}{
+			"term": map[string]interface{}{
+				"phone_e164": e164,
+			},
+		},
+	})
+}
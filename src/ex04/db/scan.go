@@ -0,0 +1,139 @@
+package db
+
+import (
+	"Day03/ex04/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	scanPageSize  = 1000
+	scanKeepAlive = "1m"
+)
+
+type pit struct {
+	Id        string `json:"id"`
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+type scanQuery struct {
+	Size        int                    `json:"size"`
+	Query       map[string]interface{} `json:"query,omitempty"`
+	Pit         pit                    `json:"pit"`
+	Sort        []map[string]string    `json:"sort"`
+	SearchAfter []interface{}          `json:"search_after,omitempty"`
+}
+
+// searchQuery пустая строка - весь индекс, иначе полнотекстовый поиск по названию и адресу.
+// Подполя translit приводят кириллицу к транслиту, поэтому запрос можно писать на любой
+// раскладке и даже вперемешку; *_cyr есть, только если загрузчик запускали с -cyrillic
+func searchQuery(q string) map[string]interface{} {
+	if strings.TrimSpace(q) == "" {
+		return nil
+	}
+	return map[string]interface{}{
+		"multi_match": map[string]interface{}{
+			"query":     q,
+			"fields":    []string{"name^2", "name.translit^2", "name_cyr^2", "address", "address.translit", "address_cyr"},
+			"operator":  "and",
+			"fuzziness": "AUTO",
+		},
+	}
+}
+
+// scanFilter поиск по q среди мест, прошедших фильтры списка
+func scanFilter(q string, filter types.PlaceFilter) map[string]interface{} {
+	text, filtered := searchQuery(q), filterQuery(filter)
+	if filtered == nil {
+		return text
+	}
+	if text != nil {
+		filtered["bool"].(map[string]interface{})["must"] = text
+	}
+	return filtered
+}
+
+// ScanPlaces обходит индекс через point in time, чтобы выгрузка не зависела от max_result_window
+// и не видела изменений, сделанных во время обхода
+func (s *ElasticSearchStore) ScanPlaces(ctx context.Context, q string, filter types.PlaceFilter, fn func(types.PlaceDocResponse) error) error {
+	const op = "ElasticSearchStore.ScanPlaces"
+	res, err := s.Es.OpenPointInTime([]string{s.Index}, scanKeepAlive, s.Es.OpenPointInTime.WithContext(ctx))
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	var opened pit
+	err = json.NewDecoder(res.Body).Decode(&opened)
+	_ = res.Body.Close()
+	if res.IsError() {
+		return errors.New(op + ": " + res.Status())
+	}
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	defer s.closePit(opened.Id)
+
+	query := scanQuery{
+		Size:  scanPageSize,
+		Query: scanFilter(q, filter),
+		Pit:   pit{Id: opened.Id, KeepAlive: scanKeepAlive},
+		Sort:  []map[string]string{{"_shard_doc": "asc"}},
+	}
+	for {
+		hits, err := s.scanPage(ctx, query)
+		if err != nil {
+			return errors.New(op + ": " + err.Error())
+		}
+		for _, hit := range hits {
+			if err := fn(types.PlaceDocResponse{Id: hit.Id, PlaceDoc: hit.Source}); err != nil {
+				return err
+			}
+		}
+		if len(hits) < scanPageSize {
+			return nil
+		}
+		query.SearchAfter = hits[len(hits)-1].Sort
+	}
+}
+
+type scanHit struct {
+	Id     string         `json:"_id"`
+	Source types.PlaceDoc `json:"_source"`
+	Sort   []interface{}  `json:"sort"`
+}
+
+func (s *ElasticSearchStore) scanPage(ctx context.Context, query scanQuery) ([]scanHit, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.Es.Search(s.Es.Search.WithContext(ctx), s.Es.Search.WithBody(bytes.NewReader(body)))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return nil, errors.New(res.String())
+	}
+	var resBody struct {
+		Hits struct {
+			Hits []scanHit `json:"hits"`
+		} `json:"hits"`
+	}
+	decoder := json.NewDecoder(res.Body)
+	decoder.UseNumber() // значения _shard_doc не влезают в float64 без потери точности
+	if err := decoder.Decode(&resBody); err != nil {
+		return nil, err
+	}
+	return resBody.Hits.Hits, nil
+}
+
+func (s *ElasticSearchStore) closePit(id string) {
+	body, _ := json.Marshal(pit{Id: id})
+	res, err := s.Es.ClosePointInTime(s.Es.ClosePointInTime.WithBody(bytes.NewReader(body)))
+	if err == nil {
+		_ = res.Body.Close()
+	}
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"Day03/ex04/types"
+	"testing"
+)
+
+func TestScanFilter(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name   string
+		q      string
+		filter types.PlaceFilter
+		want   string
+	}{
+		{"everything", " ", types.PlaceFilter{}, `null`},
+		{"filter only", "", types.PlaceFilter{HasPhone: &yes}, `{"bool":{"filter":[{"exists":{"field":"phone_e164"}}]}}`},
+		{
+			"search only", "kafe", types.PlaceFilter{},
+			`{"multi_match":{"fields":["name^2","name.translit^2","name_cyr^2","address","address.translit","address_cyr"],"fuzziness":"AUTO","operator":"and","query":"kafe"}}`,
+		},
+		{
+			"search within filter", "kafe", types.PlaceFilter{City: "Moskva"},
+			`{"bool":{"filter":[{"term":{"address_parts.city.keyword":"Moskva"}}],"must":{"multi_match":{"fields":["name^2","name.translit^2","name_cyr^2","address","address.translit","address_cyr"],"fuzziness":"AUTO","operator":"and","query":"kafe"}}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toJson(t, scanFilter(tt.q, tt.filter)); got != tt.want {
+				t.Errorf("scanFilter =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
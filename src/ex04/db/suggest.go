@@ -0,0 +1,83 @@
+package db
+
+import (
+	"Day03/ex04/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"strings"
+)
+
+// Suggest поиск по префиксу через search_as_you_type подполя name.suggest и address.suggest
+func (s *ElasticSearchStore) Suggest(prefix string, size int) ([]types.Suggestion, error) {
+	const op = "ElasticSearchStore.Suggest"
+	body, err := json.Marshal(map[string]interface{}{
+		"size":             size,
+		"_source":          []string{"name", "address"},
+		"track_total_hits": false,
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query": prefix,
+				"type":  "bool_prefix",
+				"fields": []string{
+					"name.suggest^3", "name.suggest._2gram^3", "name.suggest._3gram^3",
+					"address.suggest", "address.suggest._2gram", "address.suggest._3gram",
+				},
+			},
+		},
+		"highlight": map[string]interface{}{
+			// названия пишут через API, без экранирования в подсветку попал бы чужой html
+			"encoder": "html",
+			"fields": map[string]interface{}{
+				"name.suggest":    map[string]interface{}{},
+				"address.suggest": map[string]interface{}{},
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	req := esapi.SearchRequest{
+		Index: []string{s.Index},
+		Body:  bytes.NewReader(body),
+	}
+	res, err := req.Do(context.Background(), s.Es)
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return nil, errors.New(op + ": " + res.Status())
+	}
+	var resBody struct {
+		Hits struct {
+			Hits []struct {
+				Id     string `json:"_id"`
+				Source struct {
+					Name    string `json:"name"`
+					Address string `json:"address"`
+				} `json:"_source"`
+				Highlight map[string][]string `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resBody); err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	suggestions := make([]types.Suggestion, 0, len(resBody.Hits.Hits))
+	for _, hit := range resBody.Hits.Hits {
+		highlight := make(map[string][]string, len(hit.Highlight))
+		for field, fragments := range hit.Highlight {
+			highlight[strings.TrimSuffix(field, ".suggest")] = fragments
+		}
+		suggestions = append(suggestions, types.Suggestion{
+			Id:        hit.Id,
+			Name:      hit.Source.Name,
+			Address:   hit.Source.Address,
+			Highlight: highlight,
+		})
+	}
+	return suggestions, nil
+}
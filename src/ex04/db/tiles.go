@@ -0,0 +1,58 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+const (
+	// MinPointsZoom с этого зума отдаем сами места, раньше только кластеры (слой aggs)
+	MinPointsZoom = 13
+	tileGridPrec  = 8
+	maxTilePlaces = 10000
+)
+
+type mvtQuery struct {
+	Fields         []string `json:"fields"`
+	GridAgg        string   `json:"grid_agg"`
+	GridPrecision  int      `json:"grid_precision"`
+	GridType       string   `json:"grid_type"`
+	Size           int      `json:"size"`
+	TrackTotalHits bool     `json:"track_total_hits"`
+}
+
+// GetTile проксирует _mvt api эластика, ответ - готовый Mapbox Vector Tile
+func (s *ElasticSearchStore) GetTile(z, x, y int) ([]byte, error) {
+	const op = "ElasticSearchStore.GetTile"
+	query := mvtQuery{
+		Fields:        []string{"name", "address", "phone"},
+		GridAgg:       "geotile",
+		GridPrecision: tileGridPrec,
+		GridType:      "centroid",
+	}
+	if z >= MinPointsZoom {
+		query.GridPrecision = 0
+		query.Size = maxTilePlaces
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	res, err := s.Es.SearchMvt([]string{s.Index}, "location", &x, &y, &z,
+		s.Es.SearchMvt.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.IsError() {
+		return nil, errors.New(op + ": " + res.Status())
+	}
+	tile, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	return tile, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"Day03/ex04/types"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type exporter interface {
+	begin() error
+	write(place types.PlaceDocResponse) error
+	end() error
+}
+
+// HandlerApiExportPlaces ?format=csv|ndjson|geojson, ?q= ограничивает выгрузку результатами поиска,
+// фильтры те же, что у /api/places (sort не влияет, порядок - как в индексе).
+// csv в том же виде, что materials/data.csv (через табуляцию), чтобы его можно было снова загрузить ex00
+func HandlerApiExportPlaces(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiExportPlaces"
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	filter, _, err := placeFilter(r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	buf := bufio.NewWriter(w)
+	var exp exporter
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/tab-separated-values; charset=utf-8")
+		exp = &csvExporter{w: csv.NewWriter(buf)}
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		exp = &ndjsonExporter{enc: json.NewEncoder(buf)}
+	case "geojson":
+		w.Header().Set("Content-Type", "application/geo+json")
+		exp = &geojsonExporter{w: buf}
+	default:
+		http.Error(w, op+": invalid 'format' value, expected csv, ndjson or geojson", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=places."+format)
+	// begin пишем вместе с первым документом: пока ничего не отправлено, ошибку можно вернуть статусом
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		return exp.begin()
+	}
+	err = store(r).ScanPlaces(r.Context(), r.URL.Query().Get("q"), filter, func(p types.PlaceDocResponse) error {
+		if err := start(); err != nil {
+			return err
+		}
+		return exp.write(p)
+	})
+	if err != nil && !started {
+		log.Println(op, err)
+		w.Header().Del("Content-Disposition")
+		http.Error(w, op+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		err = start()
+	}
+	if err == nil {
+		err = exp.end()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		log.Println(op, err)
+	}
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) begin() error {
+	e.w.Comma = '\t'
+	return e.w.Write([]string{"", "Name", "Address", "Phone", "Longitude", "Latitude"})
+}
+
+func (e *csvExporter) write(p types.PlaceDocResponse) error {
+	return e.w.Write([]string{
+		p.Id,
+		p.Name,
+		p.Address,
+		p.Phone,
+		strconv.FormatFloat(p.Location.Long, 'f', -1, 64),
+		strconv.FormatFloat(p.Location.Lat, 'f', -1, 64),
+	})
+}
+
+func (e *csvExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) begin() error {
+	return nil
+}
+
+func (e *ndjsonExporter) write(p types.PlaceDocResponse) error {
+	return e.enc.Encode(p)
+}
+
+func (e *ndjsonExporter) end() error {
+	return nil
+}
+
+type geojsonExporter struct {
+	w     *bufio.Writer
+	count int
+}
+
+type geoFeature struct {
+	Type       string            `json:"type"`
+	Id         string            `json:"id"`
+	Geometry   geoPoint          `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+type geoPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func (e *geojsonExporter) begin() error {
+	_, err := e.w.WriteString(`{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (e *geojsonExporter) write(p types.PlaceDocResponse) error {
+	feature, err := json.Marshal(geoFeature{
+		Type:     "Feature",
+		Id:       p.Id,
+		Geometry: geoPoint{Type: "Point", Coordinates: [2]float64{p.Location.Long, p.Location.Lat}},
+		Properties: map[string]string{
+			"name":    p.Name,
+			"address": p.Address,
+			"phone":   p.Phone,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if e.count > 0 {
+		if err := e.w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	e.count++
+	_, err = e.w.Write(feature)
+	return err
+}
+
+func (e *geojsonExporter) end() error {
+	_, err := e.w.WriteString("]}\n")
+	return err
+}
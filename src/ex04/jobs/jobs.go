@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"Day03/ex00/loader"
+	"Day03/ex00/validate"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrBusy уже запущено максимальное число задач
+var ErrBusy = errors.New("too many running jobs")
+
+const (
+	maxRowErrors = 1000
+	keepFinished = 24 * time.Hour
+)
+
+type Status string
+
+const (
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Snapshot состояние задачи для ответа /api/jobs/{id}.
+// Total - все строки файла, включая неразобранные; по завершении Indexed + Failed == Total
+type Snapshot struct {
+	Id         string            `json:"id"`
+	Status     Status            `json:"status"`
+	Total      int               `json:"total"`
+	Indexed    int               `json:"indexed"`
+	Failed     int               `json:"failed"`
+	Errors     []loader.RowError `json:"errors"`
+	Validation *validate.Report  `json:"validation,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+type Job struct {
+	mu    sync.Mutex
+	snap  Snapshot
+	owner string
+}
+
+func (j *Job) Id() string {
+	return j.snap.Id
+}
+
+// Owner субъект, запустивший задачу; статус отдается только ему
+func (j *Job) Owner() string {
+	return j.owner
+}
+
+func (j *Job) SetTotal(total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.snap.Total = total
+}
+
+func (j *Job) SetValidation(report validate.Report) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.snap.Validation = &report
+}
+
+func (j *Job) AddIndexed() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.snap.Indexed++
+}
+
+// AddError хранит только первые maxRowErrors ошибок, счетчик считает все
+func (j *Job) AddError(e loader.RowError) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.snap.Failed++
+	if len(j.snap.Errors) < maxRowErrors {
+		j.snap.Errors = append(j.snap.Errors, e)
+	}
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.snap.FinishedAt = &now
+	j.snap.Status = StatusDone
+	if err != nil {
+		j.snap.Status = StatusFailed
+		j.snap.Error = err.Error()
+	}
+}
+
+func (j *Job) Snapshot() Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	snap := j.snap
+	snap.Errors = append([]loader.RowError{}, j.snap.Errors...)
+	return snap
+}
+
+type Manager struct {
+	mu         sync.Mutex
+	jobs       map[string]*Job
+	running    int
+	maxRunning int
+}
+
+// NewManager maxRunning - сколько задач может идти одновременно
+func NewManager(maxRunning int) *Manager {
+	return &Manager{jobs: make(map[string]*Job), maxRunning: maxRunning}
+}
+
+// Start запускает run в фоне и сразу возвращает задачу; ErrBusy, если свободных мест нет
+func (m *Manager) Start(owner string, run func(j *Job) error) (*Job, error) {
+	job := &Job{
+		snap:  Snapshot{Id: newId(), Status: StatusRunning, StartedAt: time.Now(), Errors: []loader.RowError{}},
+		owner: owner,
+	}
+	m.mu.Lock()
+	if m.running >= m.maxRunning {
+		m.mu.Unlock()
+		return nil, ErrBusy
+	}
+	m.running++
+	m.cleanup()
+	m.jobs[job.Id()] = job
+	m.mu.Unlock()
+	go func() {
+		err := run(job)
+		if err != nil {
+			log.Println("job", job.Id(), err)
+		}
+		job.finish(err)
+		m.mu.Lock()
+		m.running--
+		m.mu.Unlock()
+	}()
+	return job, nil
+}
+
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+func (m *Manager) cleanup() {
+	for id, job := range m.jobs {
+		snap := job.Snapshot()
+		if snap.FinishedAt != nil && time.Since(*snap.FinishedAt) > keepFinished {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newId() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
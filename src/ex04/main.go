@@ -1,52 +1,243 @@
 package main
 
 import (
-	"Day03/ex03/db"
-	"Day03/ex03/types"
+	"Day03/ex00/loader"
+	"Day03/ex00/phone"
+	"Day03/ex00/validate"
+	"Day03/ex04/cache"
+	"Day03/ex04/db"
+	"Day03/ex04/middleware/apikey"
+	"Day03/ex04/middleware/auth"
+	"Day03/ex04/middleware/cors"
 	"Day03/ex04/middleware/jwtauth"
+	"Day03/ex04/middleware/ratelimit"
+	"Day03/ex04/middleware/secure"
+	"Day03/ex04/tlsutil"
+	"Day03/ex04/types"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"hash/fnv"
 	"html/template"
 	"log"
 	"math"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const apiKeysFile = "./api_keys.json"
+const rateLimitFile = "./ratelimit.json"
+const corsFile = "./cors.json"
+const validationFile = "./validation.json"
+const datasetsFile = "./datasets.json"
+const clientCertsFile = "./client_certs.json"
+
 type Store interface {
 	GetPlaces(limit int, offset int) ([]types.Place, int, error)
+	SearchPlaces(filter types.PlaceFilter, sort types.PlaceSort, limit int, offset int) ([]types.Place, int, map[string][]types.FacetBucket, error)
 	GetClosest(lat, lon float64) ([]types.Place, error)
+	IndexVersion() (string, time.Time, error)
+	CreatePlace(place types.PlaceDoc) (string, types.DocVersion, error)
+	GetPlace(id string) (types.PlaceDoc, types.DocVersion, error)
+	UpdatePlace(id string, place types.PlaceDoc, version types.DocVersion) (types.DocVersion, error)
+	DeletePlace(id string, version *types.DocVersion) error
+	BulkLoad(ctx context.Context, data []loader.Data, cb loader.Callbacks) error
+	ScanPlaces(ctx context.Context, q string, filter types.PlaceFilter, fn func(types.PlaceDocResponse) error) error
+	GetInBoundingBox(box types.BoundingBox, limit int, offset int) ([]types.Place, int, error)
+	GetInShape(shape types.Geometry, limit int, offset int) ([]types.Place, int, error)
+	GetClusters(box types.BoundingBox, precision int) ([]types.Cluster, error)
+	GetTile(z, x, y int) ([]byte, error)
+	Suggest(prefix string, size int) ([]types.Suggestion, error)
+	SearchText(q string, limit int, offset int) ([]types.Place, int, error)
+	GetByPhone(e164 string, limit int, offset int) ([]types.Place, int, error)
 }
 
-var base Store
-
 type Paginator struct {
 	Places []types.Place
 	Total  int
 	Page   int
 	Last   int
+	Facets map[string][]types.FacetBucket `json:",omitempty"`
+	// Query параметры фильтров и сортировки без page, для ссылок пагинации в html
+	Query template.URL `json:"-"`
 }
 
 func main() {
-	var err error
-	base, err = db.NewElasticSearchStore()
+	if len(os.Args) > 1 && os.Args[1] == "gencert" {
+		if err := runGencert(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	addr := flag.String("addr", ":8888", "listen address")
+	certFile := flag.String("tls-cert", "", "TLS certificate file, enables https")
+	keyFile := flag.String("tls-key", "", "TLS private key file")
+	clientCa := flag.String("client-ca", "", "CA bundle for client certificates (mTLS)")
+	requireClientCert := flag.Bool("require-client-cert", false, "reject connections without a valid client certificate")
+	flag.StringVar(&phone.DefaultCountry, "phone-country", phone.DefaultCountry, "default country for phone numbers without a country code")
+	flag.Parse()
+	if !phone.ValidCountry(phone.DefaultCountry) {
+		log.Fatalf("unknown country %q", phone.DefaultCountry)
+	}
+
+	es, err := db.NewElasticSearchStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ok, err := jwtauth.LoadSecret(); err != nil {
+		log.Fatal(err)
+	} else if !ok {
+		log.Printf("%s is not set, tokens are signed with a random key until restart", jwtauth.SecretEnv)
+	}
+	keys, err := loadApiKeys(apiKeysFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	certs, err := loadClientCerts(clientCertsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	limits, err := loadRateLimits(rateLimitFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	limiter := ratelimit.NewLimiter(limits)
+	corsCfg, err := loadCors(corsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	validator, err := validate.Load(validationFile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println("Listening on port 8888")
-	http.HandleFunc("/", HandlerGetPlaces)
-	http.HandleFunc("/api/places", HandlerApiGetPlaces)
-	http.HandleFunc("/api/recommend", jwtauth.JwtMiddleware(HandlerApiClosestPlaces))
-	http.HandleFunc("/api/get_token", HandlerGetToken)
-	err = http.ListenAndServe(":8888", nil)
+	datasets, err := loadDatasets(datasetsFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	byDataset, err := newTenants(datasets, es, validator,
+		jwtauth.Authenticate, keys.Authenticator(""), certs.Authenticator())
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.HandleFunc("/", limiter.Middleware("/", HandlerGetPlaces))
+	http.HandleFunc("/api/places", limiter.Middleware("/api/places", HandlerApiGetPlaces))
+	http.HandleFunc("/api/recommend", auth.Any(jwtauth.Authenticate, keys.Authenticator("recommend"), certs.Authenticator())(
+		limiter.Middleware("/api/recommend", HandlerApiClosestPlaces)))
+	http.HandleFunc("/api/get_token", tokenAuth(keys.Authenticator("recommend"), certs.Authenticator())(
+		limiter.Middleware("/api/get_token", HandlerGetToken)))
+	// токен /api/get_token выдается кому угодно, поэтому на запись он не пускает
+	writeAuth := auth.Any(keys.Authenticator("write"), certs.Authenticator())
+	http.HandleFunc("POST /api/places", writeAuth(limiter.Middleware("/api/places/write", HandlerApiCreatePlace)))
+	http.HandleFunc("GET /api/places/export", limiter.Middleware("/api/places/export", HandlerApiExportPlaces))
+	http.HandleFunc("GET /api/places/within", limiter.Middleware("/api/places/within", HandlerApiPlacesWithin))
+	http.HandleFunc("POST /api/places/within", limiter.Middleware("/api/places/within", HandlerApiPlacesWithin))
+	http.HandleFunc("GET /api/places/clusters", limiter.Middleware("/api/places/clusters", HandlerApiClusters))
+	http.HandleFunc("GET /api/places/by-phone", limiter.Middleware("/api/places/by-phone", HandlerApiPlacesByPhone))
+	http.HandleFunc("GET /api/search", limiter.Middleware("/api/search", HandlerApiSearch))
+	http.HandleFunc("GET /api/suggest", limiter.Middleware("/api/suggest", HandlerApiSuggest))
+	http.HandleFunc("GET /tiles/{z}/{x}/{tile}", limiter.Middleware("/tiles", HandlerTile))
+	http.HandleFunc("GET /places/{id}", limiter.Middleware("/places/{id}", HandlerGetPlace))
+	http.HandleFunc("GET /api/places/{id}", limiter.Middleware("/api/places/{id}", HandlerApiGetPlace))
+	http.HandleFunc("PUT /api/places/{id}", writeAuth(limiter.Middleware("/api/places/write", HandlerApiReplacePlace)))
+	http.HandleFunc("PATCH /api/places/{id}", writeAuth(limiter.Middleware("/api/places/write", HandlerApiPatchPlace)))
+	http.HandleFunc("DELETE /api/places/{id}", writeAuth(limiter.Middleware("/api/places/write", HandlerApiDeletePlace)))
+	http.HandleFunc("POST /api/places/_bulk", writeAuth(limiter.Middleware("/api/places/_bulk", HandlerApiBulkPlaces)))
+	http.HandleFunc("GET /api/jobs/{id}", writeAuth(limiter.Middleware("/api/jobs/{id}", HandlerApiGetJob)))
+	handler := secure.Middleware(secure.DefaultConfig(), cors.Middleware(corsCfg, byDataset.Middleware(http.DefaultServeMux.ServeHTTP)))
+	server := &http.Server{Addr: *addr, Handler: handler}
+	if *certFile == "" {
+		fmt.Println("Listening on", *addr)
+		err = server.ListenAndServe()
+	} else {
+		var reloader *tlsutil.CertReloader
+		reloader, err = tlsutil.NewCertReloader(*certFile, *keyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.TLSConfig, err = tlsutil.NewServerConfig(reloader, *clientCa, *requireClientCert)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Listening on", *addr, "(TLS)")
+		err = server.ListenAndServeTLS("", "")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runGencert подкоманда: go run . gencert -host localhost,127.0.0.1
+func runGencert(args []string) error {
+	fs := flag.NewFlagSet("gencert", flag.ExitOnError)
+	hosts := fs.String("host", "localhost,127.0.0.1", "comma separated hostnames and IPs")
+	certFile := fs.String("cert", "./cert.pem", "output certificate file")
+	keyFile := fs.String("key", "./key.pem", "output private key file")
+	validFor := fs.Duration("valid-for", 365*24*time.Hour, "certificate lifetime")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := tlsutil.GenerateSelfSigned(strings.Split(*hosts, ","), *validFor, *certFile, *keyFile); err != nil {
+		return err
+	}
+	fmt.Println("written", *certFile, *keyFile)
+	return nil
+}
+
+// loadApiKeys файл с ключами необязателен, без него работает только jwt
+func loadApiKeys(path string) (*apikey.KeyStore, error) {
+	keys, err := apikey.LoadKeys(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return apikey.NewKeyStore(nil), nil
+	}
+	return keys, err
+}
+
+func loadRateLimits(path string) (ratelimit.Config, error) {
+	limits, err := ratelimit.LoadConfig(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ratelimit.DefaultConfig(), nil
+	}
+	return limits, err
+}
+
+// loadClientCerts без client_certs.json любому проверенному сертификату доступен набор по умолчанию
+func loadClientCerts(path string) (auth.ClientCerts, error) {
+	certs, err := auth.LoadClientCerts(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return auth.ClientCerts{}, nil
+	}
+	return certs, err
+}
+
+// loadCors без файла cors.json чужие origin не разрешены
+func loadCors(path string) (cors.Config, error) {
+	cfg, err := cors.LoadConfig(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cors.DefaultConfig(), nil
+	}
+	return cfg, err
 }
 
 func HandlerGetToken(w http.ResponseWriter, r *http.Request) {
 	const op = "HandlerGetToken"
-	tokenString, err := jwtauth.GenerateJwt()
+	// анонимный токен привязан к ip, с которого его получили: новые токены не дают новых лимитов
+	subject := auth.Subject(r.Context())
+	if subject == "" {
+		subject = "ip:" + ratelimit.ClientIP(r)
+	}
+	// токен другого набора действует только в этом наборе
+	var tokenString string
+	var err error
+	if current := currentTenant(r).tenant; !current.isDefault {
+		tokenString, err = jwtauth.GenerateDatasetJwt(current.name, subject)
+	} else {
+		tokenString, err = jwtauth.GenerateJwt(subject)
+	}
 	if err != nil {
 		http.Error(w, op, http.StatusInternalServerError)
 		return
@@ -71,19 +262,26 @@ func HandlerApiClosestPlaces(w http.ResponseWriter, r *http.Request) {
 	lat, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
 	if err != nil {
 		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
 	}
 	lon, err := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
 	if err != nil {
 		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if notModified(w, r, cache.Geohash(lat, lon, db.GeohashPrecision)) {
+		return
 	}
-	place, err := base.GetClosest(lat, lon)
+	place, err := store(r).GetClosest(lat, lon)
 	if err != nil {
 		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
 	}
 	res := types.NewResponse(place)
 	response, err := json.MarshalIndent(res, "", "    ")
 	if err != nil {
 		http.Error(w, op+":"+err.Error(), http.StatusInternalServerError)
+		return
 	}
 	func() {
 		_, err = w.Write(response)
@@ -91,8 +289,6 @@ func HandlerApiClosestPlaces(w http.ResponseWriter, r *http.Request) {
 			log.Println(op + ":" + err.Error())
 		}
 	}()
-	w.WriteHeader(http.StatusOK)
-
 }
 
 func HandlerApiGetPlaces(w http.ResponseWriter, r *http.Request) {
@@ -109,14 +305,28 @@ func HandlerApiGetPlaces(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "In HandlerGetPlacesFunc: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	// номер страницы проверяем до ETag: на неверный page отвечаем 400, а не 304
+	if res.Page < 1 {
+		http.Error(w, "Error 400\n BadRequest \nInvalid 'page' value: '"+pageStr+"'", http.StatusBadRequest)
+		return
+	}
 	limit := 10
 	offset := (res.Page - 1) * limit
-	res.Places, res.Total, err = base.GetPlaces(limit, offset)
+	filter, sort, err := placeFilter(r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("%s query string param is empty", op), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("%s: %v", op, err), http.StatusBadRequest)
+		return
+	}
+	if notModified(w, r, strconv.Itoa(res.Page)+"|"+filter.Key()+"|"+sort.Key()) {
+		return
+	}
+	res.Places, res.Total, res.Facets, err = store(r).SearchPlaces(filter, sort, limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %v", op, err), http.StatusBadRequest)
+		return
 	}
 	res.Last = int(math.Ceil(float64(res.Total) / float64(limit)))
-	if res.Page > res.Last || res.Page < 1 {
+	if res.Page > res.Last && res.Page > 1 {
 		http.Error(w, "Error 400\n BadRequest \nInvalid 'page' value: 'foo'", http.StatusBadRequest)
 		return
 	}
@@ -133,6 +343,46 @@ func HandlerApiGetPlaces(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// placeFilter фильтры и сортировка списка мест:
+// ?city=&district=&street_type=&street=&has_phone=&name_prefix=&address=&sort=name|-name|distance&lat=&lon=
+func placeFilter(r *http.Request) (types.PlaceFilter, types.PlaceSort, error) {
+	q := r.URL.Query()
+	filter := types.PlaceFilter{
+		City:            q.Get("city"),
+		District:        q.Get("district"),
+		StreetType:      q.Get("street_type"),
+		Street:          q.Get("street"),
+		NamePrefix:      strings.TrimSpace(q.Get("name_prefix")),
+		AddressContains: strings.TrimSpace(q.Get("address")),
+	}
+	if value := q.Get("has_phone"); value != "" {
+		hasPhone, err := strconv.ParseBool(value)
+		if err != nil {
+			return types.PlaceFilter{}, types.PlaceSort{}, fmt.Errorf("invalid has_phone %q", value)
+		}
+		filter.HasPhone = &hasPhone
+	}
+	sort := types.PlaceSort{By: q.Get("sort")}
+	switch sort.By {
+	case "", types.SortName, types.SortNameDesc:
+	case types.SortDistance:
+		if q.Get("lat") == "" || q.Get("lon") == "" {
+			return types.PlaceFilter{}, types.PlaceSort{}, errors.New("sort=distance requires lat and lon")
+		}
+		origin, err := parseLatLon(q.Get("lat") + "," + q.Get("lon"))
+		if err == nil {
+			err = origin.Validate()
+		}
+		if err != nil {
+			return types.PlaceFilter{}, types.PlaceSort{}, errors.New("sort=distance: " + err.Error())
+		}
+		sort.Origin = origin
+	default:
+		return types.PlaceFilter{}, types.PlaceSort{}, fmt.Errorf("invalid sort %q, expected name, -name or distance", sort.By)
+	}
+	return filter, sort, nil
+}
+
 func HandlerGetPlaces(w http.ResponseWriter, r *http.Request) {
 	var res Paginator
 	var err error
@@ -146,17 +396,34 @@ func HandlerGetPlaces(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "In HandlerGetPlacesFunc: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	if res.Page < 1 {
+		http.Error(w, "Error 400\n BadRequest \nInvalid 'page' value: '"+pageStr+"'", http.StatusBadRequest)
+		return
+	}
 	//fmt.Println("debug")
 	limit := 10
 	offset := (res.Page - 1) * limit
-	res.Places, res.Total, err = base.GetPlaces(limit, offset)
+	filter, sort, err := placeFilter(r)
+	if err != nil {
+		http.Error(w, "In HandlerGetPlacesFunc: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if notModified(w, r, strconv.Itoa(res.Page)+"|"+filter.Key()+"|"+sort.Key()) {
+		return
+	}
+	res.Places, res.Total, _, err = store(r).SearchPlaces(filter, sort, limit, offset)
 	if err != nil {
 		http.Error(w, "In HandlerGetPlacesFunc: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	res.Last = int(math.Ceil(float64(res.Total) / float64(limit)))
+	query := r.URL.Query()
+	query.Del("page")
+	if len(query) > 0 {
+		res.Query = template.URL("&" + query.Encode())
+	}
 
-	if res.Page > res.Last || res.Page < 1 {
+	if res.Page > res.Last && res.Page > 1 {
 		http.Error(w, "Error 400\n BadRequest \nInvalid 'page' value: 'foo'", http.StatusBadRequest)
 		return
 	}
@@ -180,6 +447,32 @@ func HandlerGetPlaces(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// notModified выставляет ETag/Last-Modified по версии индекса и параметрам запроса
+// и отвечает 304, если у клиента уже актуальная версия
+func notModified(w http.ResponseWriter, r *http.Request, key string) bool {
+	version, modified, err := store(r).IndexVersion()
+	if err != nil || version == "" {
+		return false
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(currentTenant(r).tenant.name + "|" + version + "|" + r.URL.Path + "|" + key))
+	etag := fmt.Sprintf("\"%x\"", h.Sum64())
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if match != etag && match != "*" {
+			return false
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil ||
+		modified.IsZero() || modified.Truncate(time.Second).After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
 func sum(x, y int) int {
 	return x + y
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"Day03/ex04/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPlaceFilter(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name       string
+		query      string
+		wantFilter types.PlaceFilter
+		wantSort   types.PlaceSort
+		wantErr    bool
+	}{
+		{"empty", "", types.PlaceFilter{}, types.PlaceSort{}, false},
+		{
+			"address filters", "city=Moskva&district=Zelenograd&street_type=ulitsa&street=Arbat&name_prefix=+Kafe+&address=+dom+1+",
+			types.PlaceFilter{City: "Moskva", District: "Zelenograd", StreetType: "ulitsa", Street: "Arbat", NamePrefix: "Kafe", AddressContains: "dom 1"},
+			types.PlaceSort{}, false,
+		},
+		{"has phone", "has_phone=true", types.PlaceFilter{HasPhone: &yes}, types.PlaceSort{}, false},
+		{"no phone", "has_phone=0", types.PlaceFilter{HasPhone: &no}, types.PlaceSort{}, false},
+		{"invalid has_phone", "has_phone=maybe", types.PlaceFilter{}, types.PlaceSort{}, true},
+		{"sort by name", "sort=name", types.PlaceFilter{}, types.PlaceSort{By: types.SortName}, false},
+		{"sort by name desc", "sort=-name", types.PlaceFilter{}, types.PlaceSort{By: types.SortNameDesc}, false},
+		{
+			"sort by distance", "sort=distance&lat=55.75&lon=37.62",
+			types.PlaceFilter{}, types.PlaceSort{By: types.SortDistance, Origin: types.Location{Lat: 55.75, Long: 37.62}}, false,
+		},
+		{"distance without lon", "sort=distance&lat=55.75", types.PlaceFilter{}, types.PlaceSort{}, true},
+		{"distance with bad lat", "sort=distance&lat=north&lon=37.62", types.PlaceFilter{}, types.PlaceSort{}, true},
+		{"distance out of range", "sort=distance&lat=95&lon=37.62", types.PlaceFilter{}, types.PlaceSort{}, true},
+		{"unknown sort", "sort=rating", types.PlaceFilter{}, types.PlaceSort{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, sort, err := placeFilter(httptest.NewRequest("GET", "/api/places?"+tt.query, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, wantErr %v", err, tt.wantErr)
+			}
+			if filter.Key() != tt.wantFilter.Key() || sort != tt.wantSort {
+				t.Errorf("placeFilter = %+v, %+v; want %+v, %+v", filter, sort, tt.wantFilter, tt.wantSort)
+			}
+		})
+	}
+}
+
+// неверный page отвергается до обращения к индексу, даже с If-None-Match
+func TestPlacesInvalidPage(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{"api": HandlerApiGetPlaces, "html": HandlerGetPlaces}
+	for name, handler := range handlers {
+		for _, page := range []string{"", "0", "-1", "foo"} {
+			r := httptest.NewRequest("GET", "/?page="+page, nil)
+			r.Header.Set("If-None-Match", "*")
+			rec := httptest.NewRecorder()
+			handler(rec, r)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s page=%q: status %d, want 400", name, page, rec.Code)
+			}
+		}
+	}
+}
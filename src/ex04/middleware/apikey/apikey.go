@@ -0,0 +1,120 @@
+package apikey
+
+import (
+	"Day03/ex04/middleware/auth"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const HeaderName = "X-API-Key"
+
+// Key описание ключа в конфиге: храним только sha256 от ключа.
+// Datasets - доступные наборы данных, пустой список - только набор по умолчанию
+type Key struct {
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Scopes    []string  `json:"scopes"`
+	Datasets  []string  `json:"datasets,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type config struct {
+	Keys []Key `json:"keys"`
+}
+
+type KeyStore struct {
+	keys map[string]Key // по hex(sha256(key))
+}
+
+func NewKeyStore(keys []Key) *KeyStore {
+	store := &KeyStore{keys: make(map[string]Key, len(keys))}
+	for _, k := range keys {
+		store.keys[strings.ToLower(k.Hash)] = k
+	}
+	return store
+}
+
+func LoadKeys(path string) (*KeyStore, error) {
+	const op = "LoadKeys"
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	var cfg config
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	for _, k := range cfg.Keys {
+		if len(k.Hash) != sha256.Size*2 {
+			return nil, errors.New(op + ": invalid hash for key " + k.Name)
+		}
+	}
+	return NewKeyStore(cfg.Keys), nil
+}
+
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify проверяет ключ и возвращает его имя
+func (s *KeyStore) Verify(key string, scope string) (string, error) {
+	k, err := s.lookup(key, scope)
+	if err != nil {
+		return "", err
+	}
+	return k.Name, nil
+}
+
+func (s *KeyStore) lookup(key string, scope string) (Key, error) {
+	const op = "Verify"
+	k, ok := s.keys[HashKey(key)]
+	if !ok {
+		return Key{}, errors.New(op + " unknown key")
+	}
+	if !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt) {
+		return Key{}, errors.New(op + " key expired")
+	}
+	if scope != "" && !k.hasScope(scope) {
+		return Key{}, auth.ErrForbidden
+	}
+	return k, nil
+}
+
+func (k Key) hasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator для комбинации с jwt через auth.Any
+func (s *KeyStore) Authenticator(scope string) auth.Authenticator {
+	return func(r *http.Request) (string, error) {
+		key := r.Header.Get(HeaderName)
+		if key == "" {
+			return "", auth.ErrNoCredentials
+		}
+		k, err := s.lookup(key, scope)
+		if err != nil {
+			return "", err
+		}
+		if !auth.DatasetAllowed(r.Context(), k.Datasets) {
+			return "", auth.ErrForbidden
+		}
+		return "apikey:" + k.Name, nil
+	}
+}
+
+func ApiKeyMiddleware(s *KeyStore, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return auth.Any(s.Authenticator(scope))(next)
+}
@@ -0,0 +1,110 @@
+package apikey
+
+import (
+	"Day03/ex04/middleware/auth"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHashKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"test", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+	for _, tt := range tests {
+		if got := HashKey(tt.key); got != tt.want {
+			t.Errorf("HashKey(%q) = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+}
+
+func testStore() *KeyStore {
+	return NewKeyStore([]Key{
+		{Name: "reader", Hash: HashKey("reader-key"), Scopes: []string{"recommend"}},
+		{Name: "admin", Hash: HashKey("admin-key"), Scopes: []string{"*"}},
+		{Name: "old", Hash: HashKey("old-key"), Scopes: []string{"*"}, ExpiresAt: time.Now().Add(-time.Hour)},
+		// хэш в конфиге может быть в любом регистре
+		{Name: "upper", Hash: "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855", Scopes: []string{"write"}},
+	})
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		scope   string
+		want    string
+		wantErr error
+	}{
+		{"scope granted", "reader-key", "recommend", "reader", nil},
+		{"any scope", "reader-key", "", "reader", nil},
+		{"scope denied", "reader-key", "write", "", auth.ErrForbidden},
+		{"wildcard scope", "admin-key", "write", "admin", nil},
+		{"expired", "old-key", "write", "", errors.New("expired")},
+		{"unknown key", "nope", "", "", errors.New("unknown")},
+		{"upper case hash", "", "write", "upper", nil},
+	}
+	s := testStore()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Verify(tt.key, tt.scope)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr != nil && err == nil {
+				t.Fatalf("expected error, got %q", got)
+			}
+			if errors.Is(tt.wantErr, auth.ErrForbidden) && !errors.Is(err, auth.ErrForbidden) {
+				t.Fatalf("error %v, want ErrForbidden", err)
+			}
+			if got != tt.want {
+				t.Errorf("Verify = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorDatasets(t *testing.T) {
+	s := NewKeyStore([]Key{
+		{Name: "default-only", Hash: HashKey("k1"), Scopes: []string{"write"}},
+		{Name: "spb", Hash: HashKey("k2"), Scopes: []string{"write"}, Datasets: []string{"spb"}},
+		{Name: "all", Hash: HashKey("k3"), Scopes: []string{"write"}, Datasets: []string{"*"}},
+	})
+	tests := []struct {
+		name      string
+		key       string
+		dataset   string
+		isDefault bool
+		want      string
+		wantErr   error
+	}{
+		{"no key", "", "places", true, "", auth.ErrNoCredentials},
+		{"default dataset", "k1", "places", true, "apikey:default-only", nil},
+		{"other dataset without grant", "k1", "spb", false, "", auth.ErrForbidden},
+		{"granted dataset", "k2", "spb", false, "apikey:spb", nil},
+		{"granted key on default dataset", "k2", "places", true, "", auth.ErrForbidden},
+		{"wildcard", "k3", "spb", false, "apikey:all", nil},
+	}
+	authenticate := s.Authenticator("write")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/places", nil)
+			if tt.key != "" {
+				r.Header.Set(HeaderName, tt.key)
+			}
+			r = r.WithContext(auth.WithDataset(r.Context(), tt.dataset, tt.isDefault))
+			got, err := authenticate(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("subject %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Authenticator проверяет запрос и возвращает субъекта (кому выдан токен/ключ)
+type Authenticator func(r *http.Request) (string, error)
+
+type subjectKey struct{}
+
+type datasetKey struct{}
+
+type targetDataset struct {
+	name      string
+	isDefault bool
+}
+
+var (
+	ErrNoCredentials = errors.New("no credentials")
+	ErrForbidden     = errors.New("forbidden")
+)
+
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+func Subject(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+// WithDataset набор данных, к которому обращается запрос; его проверяют способы авторизации
+func WithDataset(ctx context.Context, name string, isDefault bool) context.Context {
+	return context.WithValue(ctx, datasetKey{}, targetDataset{name: name, isDefault: isDefault})
+}
+
+// DatasetAllowed allowed - наборы субъекта: пустой список - только набор по умолчанию, "*" - любой.
+// Запросу вне наборов данных проверять нечего
+func DatasetAllowed(ctx context.Context, allowed []string) bool {
+	target, ok := ctx.Value(datasetKey{}).(targetDataset)
+	if !ok {
+		return true
+	}
+	if len(allowed) == 0 {
+		return target.isDefault
+	}
+	for _, name := range allowed {
+		if name == target.name || name == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientCert субъект из CN проверенного клиентского сертификата (mTLS)
+func ClientCert(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", ErrNoCredentials
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if cn == "" {
+		return "", ErrNoCredentials
+	}
+	return "cert:" + cn, nil
+}
+
+// Check субъект первого прошедшего способа авторизации; ErrForbidden, если учетные данные
+// верные, но доступа не дают, иначе ErrNoCredentials
+func Check(r *http.Request, authenticators ...Authenticator) (string, error) {
+	result := ErrNoCredentials
+	for _, authenticate := range authenticators {
+		subject, err := authenticate(r)
+		if err == nil {
+			return subject, nil
+		}
+		if errors.Is(err, ErrForbidden) {
+			result = ErrForbidden
+		}
+	}
+	return "", result
+}
+
+// Status код ответа для ошибки Check
+func Status(err error) int {
+	if errors.Is(err, ErrForbidden) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
+
+// Any пропускает запрос, если хотя бы один из способов авторизации прошел
+func Any(authenticators ...Authenticator) func(next http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			subject, err := Check(r, authenticators...)
+			if err != nil {
+				status := Status(err)
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			next(w, r.WithContext(WithSubject(r.Context(), subject)))
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDatasetAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		dataset   string
+		isDefault bool
+		allowed   []string
+		want      bool
+	}{
+		{"default dataset, no grants", "places", true, nil, true},
+		{"other dataset, no grants", "spb", false, nil, false},
+		{"granted", "spb", false, []string{"kzn", "spb"}, true},
+		{"not granted", "spb", false, []string{"kzn"}, false},
+		{"grants replace the default", "places", true, []string{"spb"}, false},
+		{"wildcard", "spb", false, []string{"*"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithDataset(context.Background(), tt.dataset, tt.isDefault)
+			if got := DatasetAllowed(ctx, tt.allowed); got != tt.want {
+				t.Errorf("DatasetAllowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if !DatasetAllowed(context.Background(), nil) {
+		t.Error("request outside datasets must be allowed")
+	}
+}
+
+func fixed(subject string, err error) Authenticator {
+	return func(r *http.Request) (string, error) { return subject, err }
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		authenticators []Authenticator
+		want           string
+		wantErr        error
+		wantStatus     int
+	}{
+		{"first success wins", []Authenticator{fixed("", ErrNoCredentials), fixed("a", nil), fixed("b", nil)}, "a", nil, 0},
+		{"no credentials", []Authenticator{fixed("", ErrNoCredentials)}, "", ErrNoCredentials, http.StatusUnauthorized},
+		{"forbidden beats no credentials", []Authenticator{fixed("", ErrForbidden), fixed("", ErrNoCredentials)}, "", ErrForbidden, http.StatusForbidden},
+		{"invalid credentials", []Authenticator{fixed("", errors.New("bad token"))}, "", ErrNoCredentials, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Check(httptest.NewRequest("GET", "/", nil), tt.authenticators...)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Fatalf("Check = %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+			if err != nil && Status(err) != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", Status(err), tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAnySetsSubject(t *testing.T) {
+	var subject string
+	h := Any(fixed("apikey:a", nil))(func(w http.ResponseWriter, r *http.Request) {
+		subject = Subject(r.Context())
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if subject != "apikey:a" {
+		t.Errorf("subject %q, want apikey:a", subject)
+	}
+	rec := httptest.NewRecorder()
+	Any(fixed("", ErrForbidden))(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called without credentials")
+	})(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status %d, want 403", rec.Code)
+	}
+}
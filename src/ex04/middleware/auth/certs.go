@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ClientCerts наборы данных, доступные клиентским сертификатам по CN.
+// Сертификату не из списка доступен только набор по умолчанию
+type ClientCerts struct {
+	Certs []CertAccess `json:"certs"`
+}
+
+type CertAccess struct {
+	CN       string   `json:"cn"`
+	Datasets []string `json:"datasets"`
+}
+
+func LoadClientCerts(path string) (ClientCerts, error) {
+	const op = "auth.LoadClientCerts"
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return ClientCerts{}, fmt.Errorf("%s: %w", op, err)
+	}
+	var cfg ClientCerts
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return ClientCerts{}, errors.New(op + ": " + err.Error())
+	}
+	for _, c := range cfg.Certs {
+		if c.CN == "" {
+			return ClientCerts{}, errors.New(op + ": empty cn")
+		}
+	}
+	return cfg, nil
+}
+
+func (c ClientCerts) datasets(subject string) []string {
+	cn := strings.TrimPrefix(subject, "cert:")
+	for _, access := range c.Certs {
+		if access.CN == cn {
+			return access.Datasets
+		}
+	}
+	return nil
+}
+
+// Authenticator ClientCert с проверкой набора данных запроса
+func (c ClientCerts) Authenticator() Authenticator {
+	return func(r *http.Request) (string, error) {
+		subject, err := ClientCert(r)
+		if err != nil {
+			return "", err
+		}
+		if !DatasetAllowed(r.Context(), c.datasets(subject)) {
+			return "", ErrForbidden
+		}
+		return subject, nil
+	}
+}
@@ -0,0 +1,100 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"exact", []string{"https://map.example.com"}, "https://map.example.com", true},
+		{"case insensitive", []string{"https://Map.Example.com"}, "https://map.example.com", true},
+		{"other origin", []string{"https://map.example.com"}, "https://evil.example.com", false},
+		{"scheme matters", []string{"https://map.example.com"}, "http://map.example.com", false},
+		{"wildcard", []string{"*"}, "https://any.example.com", true},
+		{"empty list", nil, "https://map.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Config{AllowedOrigins: tt.allowed}).originAllowed(tt.origin); got != tt.want {
+				t.Errorf("originAllowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"wildcard without credentials", Config{AllowedOrigins: []string{"*"}}, false},
+		{"credentials with list", Config{AllowedOrigins: []string{"https://a.example.com"}, AllowCredentials: true}, false},
+		{"wildcard with credentials", Config{AllowedOrigins: []string{"https://a.example.com", "*"}, AllowCredentials: true}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AllowedOrigins = []string{"https://map.example.com"}
+	cfg.AllowCredentials = true
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantMaxAge  string
+		wantExposed bool
+		wantNext    bool
+	}{
+		{"no origin", "GET", "", false, http.StatusOK, "", "", false, true},
+		{"allowed origin", "GET", "https://map.example.com", false, http.StatusOK, "https://map.example.com", "", true, true},
+		{"foreign origin", "GET", "https://evil.example.com", false, http.StatusOK, "", "", false, true},
+		{"preflight", "OPTIONS", "https://map.example.com", true, http.StatusNoContent, "https://map.example.com", "600", false, false},
+		{"options without request method", "OPTIONS", "https://map.example.com", false, http.StatusOK, "https://map.example.com", "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := Middleware(cfg, func(w http.ResponseWriter, r *http.Request) { called = true })
+			r := httptest.NewRequest(tt.method, "/api/places", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			rec := httptest.NewRecorder()
+			h(rec, r)
+			if rec.Code != tt.wantStatus || called != tt.wantNext {
+				t.Fatalf("status %d, next %v; want %d, %v", rec.Code, called, tt.wantStatus, tt.wantNext)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Allow-Origin %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("Max-Age %q, want %q", got, tt.wantMaxAge)
+			}
+			if got := rec.Header().Get("Access-Control-Expose-Headers") != ""; got != tt.wantExposed {
+				t.Errorf("Expose-Headers set %v, want %v", got, tt.wantExposed)
+			}
+			if tt.wantOrigin != "" && rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("Allow-Credentials not set")
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package jwtauth
 
 import (
+	"Day03/ex04/middleware/auth"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 
 	//"github.com/dgrijalva/jwt-go"
@@ -11,20 +14,60 @@ import (
 	"time"
 )
 
-var secretKey = []byte{125}
+// SecretEnv переменная окружения с ключом подписи токенов
+const SecretEnv = "JWT_SECRET"
+
+const minSecretSize = 32
+
+var secretKey = randomSecret()
+
+func randomSecret() []byte {
+	key := make([]byte, minSecretSize)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// LoadSecret ключ из JWT_SECRET; без переменной остается случайный ключ процесса,
+// и выданные токены не переживают перезапуск сервера. Возвращает false в этом случае
+func LoadSecret() (bool, error) {
+	const op = "jwtauth.LoadSecret"
+	secret := os.Getenv(SecretEnv)
+	if secret == "" {
+		return false, nil
+	}
+	if len(secret) < minSecretSize {
+		return false, fmt.Errorf("%s: %s must be at least %d bytes", op, SecretEnv, minSecretSize)
+	}
+	secretKey = []byte(secret)
+	return true, nil
+}
 
 type TokenJwt struct {
 	Token string `json:"token"`
 }
 
-func GenerateJwt() (string, error) {
+// DatasetClaim claim с именем набора данных: токен с ним дает доступ только к этому набору
+const DatasetClaim = "dataset"
+
+// GenerateJwt subject - кому выдан токен, по нему считаются лимиты запросов
+func GenerateJwt(subject string) (string, error) {
+	return GenerateDatasetJwt("", subject)
+}
+
+func GenerateDatasetJwt(dataset string, subject string) (string, error) {
 	const op = "GenerateJwt issue"
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	mapClaims := jwt.MapClaims{
 		"iss": "todo-app",
+		"sub": subject,
 		"exp": time.Now().Add(time.Hour).Unix(),
 		"iat": time.Now().Unix(),
-	})
-	fmt.Printf("Token claims added: %+v\n", claims)
+	}
+	if dataset != "" {
+		mapClaims[DatasetClaim] = dataset
+	}
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims)
 	tokenString, err := claims.SignedString(secretKey)
 	if err != nil {
 		return "", errors.New(op + " " + err.Error())
@@ -46,6 +89,57 @@ func VerifyToken(tokenString string) (bool, error) {
 	return true, nil
 }
 
+// Authenticate для комбинации с другими способами авторизации через auth.Any.
+// Токен без claim dataset действует только в наборе по умолчанию
+func Authenticate(r *http.Request) (string, error) {
+	const op = "Authenticate"
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if authHeader == "" || tokenString == authHeader {
+		return "", auth.ErrNoCredentials
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return secretKey, nil
+	})
+	if err != nil {
+		return "", errors.New(op + " " + err.Error())
+	}
+	if !token.Valid {
+		return "", errors.New(op + " invalid token")
+	}
+	var allowed []string
+	if dataset, _ := claims[DatasetClaim].(string); dataset != "" {
+		allowed = []string{dataset}
+	}
+	if !auth.DatasetAllowed(r.Context(), allowed) {
+		return "", auth.ErrForbidden
+	}
+	subject, _ := token.Claims.GetSubject()
+	if subject == "" {
+		subject, _ = token.Claims.GetIssuer()
+	}
+	return "jwt:" + subject, nil
+}
+
+// Dataset claim dataset из валидного токена; без токена или с невалидным - пустая строка,
+// отказ в доступе остается за Authenticate на защищенных маршрутах
+func Dataset(r *http.Request) string {
+	tokenString, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return ""
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return secretKey, nil
+	})
+	if err != nil || !token.Valid {
+		return ""
+	}
+	dataset, _ := claims[DatasetClaim].(string)
+	return dataset
+}
+
 func JwtMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
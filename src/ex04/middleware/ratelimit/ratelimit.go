@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"Day03/ex04/middleware/auth"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const idleTimeout = 10 * time.Minute
+
+// Limit rate - токенов в секунду, burst - размер ведра
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+type Config struct {
+	Default Limit            `json:"default"`
+	Routes  map[string]Limit `json:"routes"`
+}
+
+func DefaultConfig() Config {
+	return Config{Default: Limit{Rate: 5, Burst: 10}}
+}
+
+func LoadConfig(path string) (Config, error) {
+	const op = "ratelimit.LoadConfig"
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		return Config{}, errors.New(op + ": " + err.Error())
+	}
+	return cfg, nil
+}
+
+func (c Config) limitFor(route string) Limit {
+	l, ok := c.Routes[route]
+	if !ok {
+		l = c.Default
+	}
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+	return l
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type Limiter struct {
+	cfg       Config
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:       cfg,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow забирает токен из ведра; возвращает остаток и через сколько появится следующий токен
+func (l *Limiter) allow(key string, limit Limit, now time.Time) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// clientKey субъект, если запрос уже прошел авторизацию, иначе ip клиента.
+// Непроверенные заголовки в ключ не берем: новый ключ на каждый запрос давал бы новое ведро
+func clientKey(r *http.Request) string {
+	if subject := auth.Subject(r.Context()); subject != "" {
+		return subject
+	}
+	return "ip:" + ClientIP(r)
+}
+
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (l *Limiter) Middleware(route string, next http.HandlerFunc) http.HandlerFunc {
+	limit := l.cfg.limitFor(route)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if limit.Rate <= 0 {
+			next(w, r)
+			return
+		}
+		allowed, remaining, wait := l.allow(route+"|"+clientKey(r), limit, time.Now())
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !allowed {
+			retry := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(wait).Unix(), 10))
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		full := time.Duration(float64(limit.Burst-remaining) / limit.Rate * float64(time.Second))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(full).Unix(), 10))
+		next(w, r)
+	}
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"Day03/ex04/middleware/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 3}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		after         time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantWait      time.Duration
+	}{
+		{"full bucket", 0, true, 2, 0},
+		{"second", 0, true, 1, 0},
+		{"third", 0, true, 0, 0},
+		{"empty bucket", 0, false, 0, 500 * time.Millisecond},
+		{"half refilled", 250 * time.Millisecond, false, 0, 250 * time.Millisecond},
+		{"one token refilled", 500 * time.Millisecond, true, 0, 0},
+		{"refill is capped by burst", time.Hour, true, 2, 0},
+	}
+	l := NewLimiter(Config{Default: limit})
+	for _, tt := range tests {
+		allowed, remaining, wait := l.allow("k", limit, start.Add(tt.after))
+		if allowed != tt.wantAllowed || remaining != tt.wantRemaining || wait != tt.wantWait {
+			t.Errorf("%s: allow = %v, %d, %v; want %v, %d, %v", tt.name, allowed, remaining, wait, tt.wantAllowed, tt.wantRemaining, tt.wantWait)
+		}
+	}
+	// у другого клиента свое ведро
+	if allowed, _, _ := l.allow("other", limit, start.Add(time.Hour)); !allowed {
+		t.Error("separate key must have its own bucket")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		apiKey  string
+		remote  string
+		want    string
+	}{
+		{"anonymous", "", "", "10.0.0.1:5555", "ip:10.0.0.1"},
+		{"unverified api key is ignored", "", "random", "10.0.0.1:5555", "ip:10.0.0.1"},
+		{"authenticated subject", "apikey:reader", "reader-key", "10.0.0.1:5555", "apikey:reader"},
+		{"ipv6", "", "", "[::1]:5555", "ip:::1"},
+		{"no port", "", "", "10.0.0.2", "ip:10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.apiKey != "" {
+				r.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.subject != "" {
+				r = r.WithContext(auth.WithSubject(r.Context(), tt.subject))
+			}
+			if got := clientKey(r); got != tt.want {
+				t.Errorf("clientKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitFor(t *testing.T) {
+	cfg := Config{
+		Default: Limit{Rate: 5, Burst: 10},
+		Routes: map[string]Limit{
+			"recommend": {Rate: 1, Burst: 2},
+			"broken":    {Rate: 1},
+		},
+	}
+	tests := []struct {
+		route string
+		want  Limit
+	}{
+		{"recommend", Limit{Rate: 1, Burst: 2}},
+		{"places", Limit{Rate: 5, Burst: 10}},
+		{"broken", Limit{Rate: 1, Burst: 1}},
+	}
+	for _, tt := range tests {
+		if got := cfg.limitFor(tt.route); got != tt.want {
+			t.Errorf("limitFor(%q) = %+v, want %+v", tt.route, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l := NewLimiter(Config{Default: Limit{Rate: 1, Burst: 1}})
+	h := l.Middleware("places", func(w http.ResponseWriter, r *http.Request) {})
+	codes := []int{http.StatusOK, http.StatusTooManyRequests}
+	for i, want := range codes {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("Retry-After %q, want 1", rec.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package secure
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const defaultCsp = "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; frame-ancestors 'none'"
+
+type Config struct {
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
+	HstsMaxAge            int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ContentSecurityPolicy: defaultCsp,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		HstsMaxAge:            31536000,
+	}
+}
+
+// Middleware добавляет заголовки безопасности; HSTS только если соединение по TLS
+func Middleware(cfg Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		if cfg.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		if cfg.ContentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
+		if r.TLS != nil && cfg.HstsMaxAge > 0 {
+			h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(cfg.HstsMaxAge)+"; includeSubDomains")
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"Day03/ex00/phone"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// HandlerApiPlacesByPhone ?phone=8 (499) 183-14-10, формат записи номера не важен
+func HandlerApiPlacesByPhone(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiPlacesByPhone"
+	e164, err := phone.Normalize(r.URL.Query().Get("phone"), phone.DefaultCountry)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := Paginator{Page: page}
+	res.Places, res.Total, err = store(r).GetByPhone(e164, pageSize, (page-1)*pageSize)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res.Last = int(math.Ceil(float64(res.Total) / float64(pageSize)))
+	if page > 1 && page > res.Last {
+		http.Error(w, op+": invalid 'page' value: "+strconv.Itoa(page), http.StatusBadRequest)
+		return
+	}
+	writeJson(w, http.StatusOK, res)
+}
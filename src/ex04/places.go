@@ -0,0 +1,251 @@
+package main
+
+import (
+	"Day03/ex04/db"
+	"Day03/ex04/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxBodySize = 1 << 20
+
+func HandlerApiCreatePlace(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiCreatePlace"
+	var place types.PlaceDoc
+	if err := decodeBody(w, r, &place); err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	warnings, err := place.Validate(validatorFor(r))
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	setWarnings(w, warnings)
+	id, version, err := store(r).CreatePlace(place)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, op+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/api/places/"+id)
+	w.Header().Set("ETag", docEtag(version))
+	writeJson(w, http.StatusCreated, types.PlaceDocResponse{Id: id, PlaceDoc: place})
+}
+
+func HandlerApiGetPlace(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiGetPlace"
+	id := r.PathValue("id")
+	place, version, err := store(r).GetPlace(id)
+	if err != nil {
+		writeStoreError(w, op, err)
+		return
+	}
+	etag := docEtag(version)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJson(w, http.StatusOK, types.PlaceDocResponse{Id: id, PlaceDoc: place})
+}
+
+func HandlerGetPlace(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerGetPlace"
+	id := r.PathValue("id")
+	place, _, err := store(r).GetPlace(id)
+	if err != nil {
+		writeStoreError(w, op, err)
+		return
+	}
+	tmpl, err := template.ParseFiles("./template/place.html")
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	err = tmpl.Execute(w, types.PlaceDocResponse{Id: id, PlaceDoc: place})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// HandlerApiReplacePlace PUT: без If-Match берем текущую версию, чтобы PUT не создавал новых документов
+func HandlerApiReplacePlace(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiReplacePlace"
+	id := r.PathValue("id")
+	var place types.PlaceDoc
+	if err := decodeBody(w, r, &place); err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	warnings, err := place.Validate(validatorFor(r))
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	setWarnings(w, warnings)
+	version, ok, err := ifMatch(r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		if _, version, err = store(r).GetPlace(id); err != nil {
+			writeStoreError(w, op, err)
+			return
+		}
+	}
+	newVersion, err := store(r).UpdatePlace(id, place, version)
+	if err != nil {
+		writeWriteError(w, op, err, ok)
+		return
+	}
+	w.Header().Set("ETag", docEtag(newVersion))
+	writeJson(w, http.StatusOK, types.PlaceDocResponse{Id: id, PlaceDoc: place})
+}
+
+// HandlerApiPatchPlace PATCH: поля из тела накладываются на текущий документ
+func HandlerApiPatchPlace(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiPatchPlace"
+	id := r.PathValue("id")
+	expected, ok, err := ifMatch(r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	place, version, err := store(r).GetPlace(id)
+	if err != nil {
+		writeStoreError(w, op, err)
+		return
+	}
+	if ok && expected != version {
+		http.Error(w, op+": "+db.ErrConflict.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if err := decodeBody(w, r, &place); err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	warnings, err := place.Validate(validatorFor(r))
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	setWarnings(w, warnings)
+	newVersion, err := store(r).UpdatePlace(id, place, version)
+	if err != nil {
+		writeWriteError(w, op, err, ok)
+		return
+	}
+	w.Header().Set("ETag", docEtag(newVersion))
+	writeJson(w, http.StatusOK, types.PlaceDocResponse{Id: id, PlaceDoc: place})
+}
+
+func HandlerApiDeletePlace(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiDeletePlace"
+	version, ok, err := ifMatch(r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var expected *types.DocVersion
+	if ok {
+		expected = &version
+	}
+	if err := store(r).DeletePlace(r.PathValue("id"), expected); err != nil {
+		writeWriteError(w, op, err, ok)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func docEtag(version types.DocVersion) string {
+	return fmt.Sprintf("\"%d-%d\"", version.SeqNo, version.PrimaryTerm)
+}
+
+// ifMatch разбирает If-Match вида "seq_no-primary_term"
+func ifMatch(r *http.Request) (types.DocVersion, bool, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return types.DocVersion{}, false, nil
+	}
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), "\"")
+	seqNo, term, found := strings.Cut(value, "-")
+	if !found {
+		return types.DocVersion{}, false, errors.New("invalid If-Match header")
+	}
+	var version types.DocVersion
+	var err error
+	if version.SeqNo, err = strconv.ParseInt(seqNo, 10, 64); err != nil {
+		return types.DocVersion{}, false, errors.New("invalid If-Match header")
+	}
+	if version.PrimaryTerm, err = strconv.ParseInt(term, 10, 64); err != nil {
+		return types.DocVersion{}, false, errors.New("invalid If-Match header")
+	}
+	return version, true, nil
+}
+
+func writeStoreError(w http.ResponseWriter, op string, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, op+": "+err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Println(err)
+	http.Error(w, op+": "+err.Error(), http.StatusInternalServerError)
+}
+
+// writeWriteError конфликт версий по If-Match клиента - 412, по нашей версии - 409
+func writeWriteError(w http.ResponseWriter, op string, err error, clientVersion bool) {
+	if errors.Is(err, db.ErrConflict) {
+		status := http.StatusConflict
+		if clientVersion {
+			status = http.StatusPreconditionFailed
+		}
+		http.Error(w, op+": "+err.Error(), status)
+		return
+	}
+	writeStoreError(w, op, err)
+}
+
+func writeValidationError(w http.ResponseWriter, err error) {
+	var verr types.ValidationErrors
+	if !errors.As(err, &verr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJson(w, http.StatusBadRequest, map[string][]string{"errors": verr})
+}
+
+// setWarnings предупреждения валидации отдаем в заголовках Warning, документ при этом сохраняется
+func setWarnings(w http.ResponseWriter, warnings []string) {
+	for _, warning := range warnings {
+		w.Header().Add("Warning", "299 - "+strconv.Quote(warning))
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	response, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "json marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err = w.Write(response); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HandlerApiSearch ?q=кофейня&page=1, запрос кириллицей находит места, записанные транслитом, и наоборот
+func HandlerApiSearch(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiSearch"
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, op+": 'q' is required", http.StatusBadRequest)
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := Paginator{Page: page}
+	res.Places, res.Total, err = store(r).SearchText(q, pageSize, (page-1)*pageSize)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res.Last = int(math.Ceil(float64(res.Total) / float64(pageSize)))
+	if page > 1 && page > res.Last {
+		http.Error(w, op+": invalid 'page' value: "+strconv.Itoa(page), http.StatusBadRequest)
+		return
+	}
+	writeJson(w, http.StatusOK, res)
+}
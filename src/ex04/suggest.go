@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultSuggestSize = 5
+	maxSuggestSize     = 20
+	maxPrefixLength    = 100
+)
+
+// HandlerApiSuggest ?prefix=Kofe&size=5
+func HandlerApiSuggest(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiSuggest"
+	prefix := strings.TrimSpace(r.URL.Query().Get("prefix"))
+	if prefix == "" || utf8.RuneCountInString(prefix) > maxPrefixLength {
+		http.Error(w, op+": 'prefix' is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	size := defaultSuggestSize
+	if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+		var err error
+		if size, err = strconv.Atoi(sizeStr); err != nil || size < 1 || size > maxSuggestSize {
+			http.Error(w, op+": invalid 'size' value, expected 1-20", http.StatusBadRequest)
+			return
+		}
+	}
+	suggestions, err := store(r).Suggest(prefix, size)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	writeJson(w, http.StatusOK, map[string]interface{}{"prefix": prefix, "suggestions": suggestions})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HandlerTile GET /tiles/{z}/{x}/{y}.mvt; до db.MinPointsZoom в тайле только кластеры
+func HandlerTile(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerTile"
+	z, errZ := strconv.Atoi(r.PathValue("z"))
+	x, errX := strconv.Atoi(r.PathValue("x"))
+	yStr, found := strings.CutSuffix(r.PathValue("tile"), ".mvt")
+	y, errY := strconv.Atoi(yStr)
+	if errZ != nil || errX != nil || errY != nil || !found {
+		http.Error(w, op+": expected /tiles/{z}/{x}/{y}.mvt", http.StatusBadRequest)
+		return
+	}
+	if z < 0 || z > maxZoom || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		http.Error(w, op+": tile coordinates out of range", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if notModified(w, r, fmt.Sprintf("%d/%d/%d", z, x, y)) {
+		return
+	}
+	tile, err := store(r).GetTile(z, x, y)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, op+": "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(tile); err != nil {
+		log.Println(op, err)
+	}
+}
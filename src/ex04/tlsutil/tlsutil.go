@@ -0,0 +1,107 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const reloadCheckTime = 5 * time.Second
+
+// CertReloader перечитывает сертификат с диска, когда меняются файлы, без рестарта сервера
+type CertReloader struct {
+	certPath string
+	keyPath  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+	checked  time.Time
+}
+
+func NewCertReloader(certPath, keyPath string) (*CertReloader, error) {
+	r := &CertReloader{certPath: certPath, keyPath: keyPath}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) reload() error {
+	const op = "CertReloader.reload"
+	modified, err := r.lastModified()
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return errors.New(op + ": " + err.Error())
+	}
+	r.cert = &cert
+	r.modified = modified
+	return nil
+}
+
+func (r *CertReloader) lastModified() (time.Time, error) {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
+
+// GetCertificate для tls.Config; если новый файл битый, продолжаем отдавать старый сертификат
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) >= reloadCheckTime {
+		r.checked = time.Now()
+		if modified, err := r.lastModified(); err == nil && modified.After(r.modified) {
+			if err := r.reload(); err != nil {
+				log.Println(err)
+			} else {
+				log.Println("TLS certificate reloaded")
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// NewServerConfig если задан clientCaPath, клиентские сертификаты проверяются по нему (mTLS).
+// requireClientCert=false оставляет возможность зайти по jwt/ключу без сертификата
+func NewServerConfig(reloader *CertReloader, clientCaPath string, requireClientCert bool) (*tls.Config, error) {
+	const op = "NewServerConfig"
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCaPath == "" {
+		return cfg, nil
+	}
+	caPem, err := os.ReadFile(clientCaPath)
+	if err != nil {
+		return nil, errors.New(op + ": " + err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPem) {
+		return nil, fmt.Errorf("%s: no certificates in %s", op, clientCaPath)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
@@ -1,9 +1,19 @@
 package types
 
+import (
+	"Day03/ex00/address"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 type Place struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
-	Phone   string `json:"phone"`
+	Id           string           `json:"id"`
+	Name         string           `json:"name"`
+	Address      string           `json:"address"`
+	AddressParts *address.Address `json:"address_parts,omitempty"`
+	Phone        string           `json:"phone"`
+	GroupId      string           `json:"group_id,omitempty"`
 }
 
 type Limits struct {
@@ -68,3 +78,114 @@ func NewResponse(places []Place) Response {
 		Places: places,
 	}
 }
+
+// PlaceDoc документ в индексе places, как его пишет загрузчик
+type PlaceDoc struct {
+	Name         string           `json:"name"`
+	Address      string           `json:"address"`
+	AddressParts *address.Address `json:"address_parts,omitempty"`
+	Phone        string           `json:"phone"`
+	PhoneE164    []string         `json:"phone_e164,omitempty"`
+	NameCyr      string           `json:"name_cyr,omitempty"`
+	AddressCyr   string           `json:"address_cyr,omitempty"`
+	GroupId      string           `json:"group_id,omitempty"`
+	Location     Location         `json:"location"`
+}
+
+// DocVersion нужна для оптимистичной блокировки (if_seq_no/if_primary_term)
+type DocVersion struct {
+	SeqNo       int64 `json:"_seq_no"`
+	PrimaryTerm int64 `json:"_primary_term"`
+}
+
+type PlaceDocResponse struct {
+	Id string `json:"id"`
+	PlaceDoc
+}
+
+// BoundingBox видимая область карты
+type BoundingBox struct {
+	TopLeft     Location `json:"top_left"`
+	BottomRight Location `json:"bottom_right"`
+}
+
+// Geometry GeoJSON геометрия, поддерживаются Polygon и MultiPolygon
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Cluster ячейка geotile_grid: ключ "z/x/y", число мест и их центр
+type Cluster struct {
+	Key      string   `json:"key"`
+	Count    int      `json:"count"`
+	Centroid Location `json:"centroid"`
+}
+
+// PlaceFilter фильтры списка мест, пустое поле - без фильтра
+type PlaceFilter struct {
+	City            string
+	District        string
+	StreetType      string
+	Street          string
+	HasPhone        *bool
+	NamePrefix      string
+	AddressContains string
+}
+
+// Fields поле в индексе -> значение, только заданные фильтры по разобранному адресу
+func (f PlaceFilter) Fields() map[string]string {
+	fields := make(map[string]string)
+	for field, value := range map[string]string{
+		"address_parts.city.keyword":        f.City,
+		"address_parts.district.keyword":    f.District,
+		"address_parts.street_type.keyword": f.StreetType,
+		"address_parts.street.keyword":      f.Street,
+	} {
+		if value != "" {
+			fields[field] = value
+		}
+	}
+	return fields
+}
+
+// Key нормализованный вид фильтра для ключей кэша и ETag; значения от клиента,
+// поэтому кодируем их в json, а не склеиваем через разделитель
+func (f PlaceFilter) Key() string {
+	key, _ := json.Marshal([]interface{}{f.City, f.District, f.StreetType, f.Street,
+		f.HasPhone, strings.ToLower(f.NamePrefix), strings.ToLower(f.AddressContains)})
+	return string(key)
+}
+
+const (
+	SortName     = "name"
+	SortNameDesc = "-name"
+	SortDistance = "distance"
+)
+
+// PlaceSort порядок списка мест; пустой By - порядок индекса, Origin нужен только для distance
+type PlaceSort struct {
+	By     string
+	Origin Location
+}
+
+// Key нормализованный вид сортировки для ключей кэша и ETag
+func (s PlaceSort) Key() string {
+	if s.By != SortDistance {
+		return s.By
+	}
+	return fmt.Sprintf("%s:%g,%g", s.By, s.Origin.Lat, s.Origin.Long)
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Suggestion подсказка для автодополнения; в Highlight совпавшая часть обернута в <em>
+type Suggestion struct {
+	Id        string              `json:"id"`
+	Name      string              `json:"name"`
+	Address   string              `json:"address"`
+	Highlight map[string][]string `json:"highlight,omitempty"`
+}
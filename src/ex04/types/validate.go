@@ -0,0 +1,95 @@
+package types
+
+import (
+	"Day03/ex00/validate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type ValidationErrors []string
+
+func (v ValidationErrors) Error() string {
+	return "validation failed: " + strings.Join(v, "; ")
+}
+
+// Validate те же правила, что и у загрузчика; исправления (severity fix) применяются к документу,
+// предупреждения возвращаются отдельно, отклоненный документ дает ValidationErrors
+func (p *PlaceDoc) Validate(v *validate.Validator) ([]string, error) {
+	r := validate.Record{Name: p.Name, Address: p.Address, Phone: p.Phone, Lat: p.Location.Lat, Lon: p.Location.Long}
+	issues, rejected := v.Check(&r)
+	var errs ValidationErrors
+	var warnings []string
+	for _, issue := range issues {
+		if issue.Severity == validate.SeverityReject {
+			errs = append(errs, issue.Message)
+		} else {
+			warnings = append(warnings, issue.String())
+		}
+	}
+	if rejected {
+		return warnings, errs
+	}
+	p.Name, p.Address, p.Phone = r.Name, r.Address, r.Phone
+	p.Location = Location{Lat: r.Lat, Long: r.Lon}
+	return warnings, nil
+}
+
+func validLocation(l Location) bool {
+	return l.Lat >= -90 && l.Lat <= 90 && l.Long >= -180 && l.Long <= 180
+}
+
+func (l Location) Validate() error {
+	if !validLocation(l) {
+		return errors.New("coordinates are out of range")
+	}
+	return nil
+}
+
+func (b BoundingBox) Validate() error {
+	if !validLocation(b.TopLeft) || !validLocation(b.BottomRight) {
+		return errors.New("bounding box corners are out of range")
+	}
+	if b.TopLeft.Lat < b.BottomRight.Lat {
+		return errors.New("top_left must be north of bottom_right")
+	}
+	return nil
+}
+
+func (g Geometry) Validate() error {
+	var polygons [][][][2]float64
+	switch g.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return errors.New("invalid polygon coordinates: " + err.Error())
+		}
+		polygons = append(polygons, polygon)
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return errors.New("invalid multipolygon coordinates: " + err.Error())
+		}
+	default:
+		return fmt.Errorf("unsupported geometry type %q, expected Polygon or MultiPolygon", g.Type)
+	}
+	if len(polygons) == 0 {
+		return errors.New("geometry has no polygons")
+	}
+	for _, polygon := range polygons {
+		if len(polygon) == 0 {
+			return errors.New("polygon has no rings")
+		}
+		for _, ring := range polygon {
+			if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+				return errors.New("polygon ring must have at least 4 points and be closed")
+			}
+			for _, p := range ring {
+				if !validLocation(Location{Long: p[0], Lat: p[1]}) {
+					return errors.New("polygon coordinates are out of range")
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"Day03/ex04/types"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const pageSize = 10
+
+// HandlerApiPlacesWithin GET ?top_left=lat,lon&bottom_right=lat,lon или
+// POST с телом {"top_left":{...},"bottom_right":{...}} либо GeoJSON Polygon/MultiPolygon (или Feature с ним)
+func HandlerApiPlacesWithin(w http.ResponseWriter, r *http.Request) {
+	const op = "HandlerApiPlacesWithin"
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	box, shape, err := parseArea(w, r)
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := Paginator{Page: page}
+	offset := (page - 1) * pageSize
+	if shape != nil {
+		res.Places, res.Total, err = store(r).GetInShape(*shape, pageSize, offset)
+	} else {
+		res.Places, res.Total, err = store(r).GetInBoundingBox(*box, pageSize, offset)
+	}
+	if err != nil {
+		http.Error(w, op+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res.Last = int(math.Ceil(float64(res.Total) / float64(pageSize)))
+	if page > 1 && page > res.Last {
+		http.Error(w, op+": invalid 'page' value: "+strconv.Itoa(page), http.StatusBadRequest)
+		return
+	}
+	writeJson(w, http.StatusOK, res)
+}
+
+// parsePage без page отдаем первую страницу
+func parsePage(r *http.Request) (int, error) {
+	pageStr := r.URL.Query().Get("page")
+	if pageStr == "" {
+		return 1, nil
+	}
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		return 0, errors.New("invalid 'page' value: " + strconv.Quote(pageStr))
+	}
+	return page, nil
+}
+
+func parseArea(w http.ResponseWriter, r *http.Request) (*types.BoundingBox, *types.Geometry, error) {
+	if r.Method == http.MethodGet {
+		box, err := boxFromQuery(r)
+		return box, nil, err
+	}
+	var body struct {
+		TopLeft     *types.Location `json:"top_left"`
+		BottomRight *types.Location `json:"bottom_right"`
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    *types.Geometry `json:"geometry"`
+		Properties  interface{}     `json:"properties"`
+	}
+	if err := decodeBody(w, r, &body); err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case body.TopLeft != nil && body.BottomRight != nil:
+		box := &types.BoundingBox{TopLeft: *body.TopLeft, BottomRight: *body.BottomRight}
+		return box, nil, box.Validate()
+	case body.Type == "Feature" && body.Geometry != nil:
+		return nil, body.Geometry, body.Geometry.Validate()
+	case body.Type != "":
+		shape := &types.Geometry{Type: body.Type, Coordinates: body.Coordinates}
+		return nil, shape, shape.Validate()
+	}
+	return nil, nil, errors.New("expected top_left/bottom_right or a GeoJSON polygon")
+}
+
+func boxFromQuery(r *http.Request) (*types.BoundingBox, error) {
+	topLeft, err := parseLatLon(r.URL.Query().Get("top_left"))
+	if err != nil {
+		return nil, errors.New("top_left: " + err.Error())
+	}
+	bottomRight, err := parseLatLon(r.URL.Query().Get("bottom_right"))
+	if err != nil {
+		return nil, errors.New("bottom_right: " + err.Error())
+	}
+	box := &types.BoundingBox{TopLeft: topLeft, BottomRight: bottomRight}
+	return box, box.Validate()
+}
+
+// parseLatLon формат "lat,lon"
+func parseLatLon(s string) (types.Location, error) {
+	latStr, lonStr, found := strings.Cut(s, ",")
+	if !found {
+		return types.Location{}, errors.New("expected 'lat,lon'")
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil {
+		return types.Location{}, err
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err != nil {
+		return types.Location{}, err
+	}
+	return types.Location{Lat: lat, Long: lon}, nil
+}
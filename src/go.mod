@@ -2,14 +2,18 @@ module Day03
 
 go 1.23.4
 
-require github.com/elastic/go-elasticsearch/v8 v8.17.0
+require (
+	github.com/elastic/go-elasticsearch/v8 v8.17.0
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/text v0.25.0
+)
 
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
 	github.com/elastic/elastic-transport-go/v8 v8.6.0 // indirect
 	github.com/go-logr/logr v1.4.2 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
 	go.opentelemetry.io/otel v1.28.0 // indirect
 	go.opentelemetry.io/otel/metric v1.28.0 // indirect
 	go.opentelemetry.io/otel/trace v1.28.0 // indirect